@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var ArchRules string
+
+// archCmd represents the arch command
+var archCmd = &cobra.Command{
+	Use:   "arch",
+	Short: "Enforce architecture layering rules.",
+	Long: `Layering rules are declared in a '` + peekr.ArchFileName + `' file next to go.mod,
+one per line:
+
+  helpers must not import peekr
+  cmd may only import peekr config
+
+Packages are named by import path relative to their module, or in
+full, and a '/...' suffix also matches the packages below. Only
+imports of workspace packages are checked; the standard library and
+external modules are always allowed.`,
+}
+
+// archCheckCmd represents the arch check command
+var archCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the imports of the workspace against the architecture rules.",
+	Long: `Parse the workspace in '-d' and check every import of one workspace
+package by another against the rules of the '` + peekr.ArchFileName + `' file found
+in '-d' or its modules, or of the file given with '--rules'. Every
+violation is listed with the position of the offending import, and so
+is every rule naming a package that matches nothing in the workspace,
+which is usually a typo. The command exits with status 1 when there
+are any, which makes it suitable as a pre-commit hook.`,
+	Args:    cobra.NoArgs,
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		report, err := peekr.CheckArchitecture(ctx, opts, ArchRules)
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		peekr.PrintArchReport(report)
+		peekr.PrintDiagnostics(report.Diagnostics)
+		if report.Failed() {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archCmd)
+	archCmd.AddCommand(archCheckCmd)
+
+	archCheckCmd.Flags().StringVar(&ArchRules, "rules", "", "Rules file to check (default: "+peekr.ArchFileName+" in -d or its modules).")
+}
@@ -0,0 +1,41 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the on-disk extraction cache.",
+	Long: `Peekr caches what it extracts from each package on disk, keyed by the
+content of the package's files and the peekr version, so repeat queries
+against unchanged packages skip parsing entirely. The cache lives in the
+user cache directory unless PEEKR_CACHE_DIR is set. Use '--no-cache' on any
+command to bypass it.`,
+}
+
+// cacheCleanCmd represents the cache clean command
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove every cached extraction result.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := peekr.CleanCache("")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed the cached entries in %s\n", dir)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+}
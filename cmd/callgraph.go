@@ -0,0 +1,82 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var CallGraphModule bool
+var CallGraphRoot string
+var CallGraphDepth int
+var CallGraphFormat string
+
+// callgraphCmd represents the callgraph command
+var callgraphCmd = &cobra.Command{
+	Use:   "callgraph",
+	Short: "Export the static call graph of a package or the whole module.",
+	Long: `Type-check every package of the workspace in '-d' and print the
+calls made by the functions of the package selected by '-p', or by
+every package of the workspace with '--module'.
+
+Calls of functions and of methods of concrete types are resolved
+through the type checker. A call through an interface is linked to
+the method of every type of the workspace that implements it. Calls of
+function values cannot be resolved statically and are left out.
+
+'--root' keeps only the functions reachable from the named function,
+such as 'main.main', and '--depth' limits how many calls away from it
+they may be.
+
+'--format' selects 'dot' (the default, for Graphviz) or 'json'.`,
+	Args:    cobra.NoArgs,
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Catch a mistyped format before type-checking the whole program.
+		if !helpers.SliceContains(peekr.CallGraphFormats, CallGraphFormat) {
+			return fmt.Errorf("unknown format %q (available: %s)", CallGraphFormat, strings.Join(peekr.CallGraphFormats, ", "))
+		}
+		opts := scanOptions()
+		if opts.Package == "" && !CallGraphModule {
+			return fmt.Errorf(`required flag(s) "package" not set (or use --module)`)
+		}
+		if CallGraphDepth > 0 && CallGraphRoot == "" {
+			return fmt.Errorf("--depth requires --root")
+		}
+
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		graph, err := peekr.BuildCallGraph(ctx, opts, peekr.CallGraphOptions{
+			Module: CallGraphModule,
+			Root:   CallGraphRoot,
+			Depth:  CallGraphDepth,
+		})
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		if err := peekr.WriteCallGraph(os.Stdout, graph, CallGraphFormat); err != nil {
+			return err
+		}
+		peekr.FprintDiagnostics(os.Stderr, graph.Diagnostics)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(callgraphCmd)
+
+	callgraphCmd.Flags().BoolVar(&CallGraphModule, "module", false, "Include the calls made by every package of the workspace.")
+	callgraphCmd.Flags().StringVar(&CallGraphRoot, "root", "", "Only keep functions reachable from this function, e.g. main.main.")
+	callgraphCmd.Flags().IntVar(&CallGraphDepth, "depth", 0, "With --root, only keep functions at most this many calls away (default: no limit).")
+	callgraphCmd.Flags().StringVar(&CallGraphFormat, "format", "dot", "Output format: "+strings.Join(peekr.CallGraphFormats, ", ")+".")
+}
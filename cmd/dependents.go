@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var DependentConsumers []string
+
+// dependentsCmd represents the dependents command
+var dependentsCmd = &cobra.Command{
+	Use:   "dependents",
+	Short: "List the packages that import a package and what they use.",
+	Long: `Type-check every package of the workspace in '-d' and list the
+packages that import the package selected by '-p', along with exactly
+which of its symbols each one uses and how often. Uses of a method or
+field are listed under the type declaring it. When several packages
+share the name, pass the import path of one of them to '-p'.
+
+'--consumer' searches the packages of other modules on disk as well,
+such as sibling services built on a shared library. External test
+packages are only searched with '--tests'.`,
+	Args:    cobra.NoArgs,
+	PreRunE: requireScanFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		result, err := peekr.FindDependents(ctx, opts, peekr.DependentsOptions{Consumers: DependentConsumers})
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		peekr.PrintDependents(result)
+		peekr.PrintDiagnostics(result.Diagnostics)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dependentsCmd)
+
+	dependentsCmd.Flags().StringSliceVar(&DependentConsumers, "consumer", nil, "Directories of other modules to search for dependents.")
+}
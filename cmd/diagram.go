@@ -0,0 +1,62 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var DiagramFormat string
+
+// diagramCmd represents the diagram command
+var diagramCmd = &cobra.Command{
+	Use:   "diagram",
+	Short: "Render a UML class diagram of a package.",
+	Long: `Render the exported structs (with their fields and methods) and
+interfaces of the package selected by '-p' as a UML class diagram.
+
+Embedding is drawn as inheritance, fields whose type refers to another
+class of the package as associations, and structs implementing an
+interface of the package as realizations. When several directories
+declare the package name, pass the import path of one of them to '-p'.
+
+'--format' selects 'mermaid' (the default), 'plantuml', 'dot' (for
+Graphviz) or 'json'.`,
+	Args:    cobra.NoArgs,
+	PreRunE: requireScanFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Catch a mistyped format before loading the package.
+		if !helpers.SliceContains(peekr.DiagramFormats, DiagramFormat) {
+			return fmt.Errorf("unknown format %q (available: %s)", DiagramFormat, strings.Join(peekr.DiagramFormats, ", "))
+		}
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		diagram, err := peekr.BuildDiagram(ctx, opts)
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		if err := peekr.WriteDiagram(os.Stdout, diagram, DiagramFormat); err != nil {
+			return err
+		}
+		peekr.FprintDiagnostics(os.Stderr, diagram.Diagnostics)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diagramCmd)
+
+	diagramCmd.Flags().StringVar(&DiagramFormat, "format", "mermaid", "Output format: "+strings.Join(peekr.DiagramFormats, ", ")+".")
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var Since string
+
+// impactCmd represents the impact command
+var impactCmd = &cobra.Command{
+	Use:   "impact",
+	Short: "Show the exported symbols and packages a change touches.",
+	Long: `Map the lines changed in the git repository containing '-d' to the
+declarations enclosing them, then list every exported symbol and
+package of the workspace that depends on those declarations, directly
+or transitively.
+
+By default the working tree, staged or not, is compared with HEAD;
+'--since' compares it with another revision instead, such as 'main'
+or 'HEAD~3'. Untracked files count as entirely changed.
+
+Uses of struct fields and interface methods count as uses of the type
+declaring them. Calls through an interface are not followed to the
+methods implementing it.`,
+	Args:    cobra.NoArgs,
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		changes, err := peekr.ChangedLines(ctx, opts.Dir, Since)
+		if err != nil {
+			exitOnScanError(err)
+		}
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		impact, err := peekr.FindImpact(ctx, opts, changes)
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		peekr.PrintImpact(impact)
+		peekr.PrintDiagnostics(impact.Diagnostics)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(impactCmd)
+
+	impactCmd.Flags().StringVar(&Since, "since", "", "Git revision to compare the working tree with (default: HEAD).")
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+// implementsCmd represents the implements command
+var implementsCmd = &cobra.Command{
+	Use:   "implements",
+	Short: "List the implementations of an interface, or the interfaces a type satisfies.",
+	Long: `Type-check every package of the workspace in '-d' and relate the
+named type to the others. The type is given as 'pkg.Type', where pkg is
+a package name or import path, e.g. 'peekr implements peekr.Renderer'
+or 'peekr implements fmt.Stringer'.
+
+For an interface, every concrete type of the workspace that satisfies
+it is listed; types that only satisfy it through a pointer receiver
+are shown as '*Type'. For any other type, every interface it satisfies
+is listed among those of the workspace, the packages it imports,
+common standard library packages such as fmt, io, sort and
+encoding/json, and the predeclared 'error'.
+
+Packages are checked for the host platform unless '--goos' or
+'--goarch' is set.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		result, err := peekr.FindImplementations(ctx, opts, args[0])
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		peekr.PrintImplementations(result)
+		peekr.PrintDiagnostics(result.Diagnostics)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(implementsCmd)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var ImportsFormat string
+var ImportsExternal bool
+
+// importsCmd represents the imports command
+var importsCmd = &cobra.Command{
+	Use:   "imports",
+	Short: "Show the import graph of the workspace packages and detect cycles.",
+	Long: `Parse the workspace in '-d' and print the import graph of the
+packages matched by the arguments, which are package patterns like
+those of the go command: './...' (the default) for every package under
+'-d', './cmd' for a single directory, or import paths such as
+'github.com/mwiater/peekr/...'.
+
+Every package is listed with its fan-in (how many packages import it)
+and fan-out (how many it imports). Import cycles, which break builds,
+are highlighted in red, and the command exits with status 1 when it
+finds any.
+
+'--external' adds the modules outside the workspace that the packages
+import, one node per module; the standard library is never shown.
+
+'--format' selects 'text' (the default), 'dot' (for Graphviz),
+'mermaid' or 'json'.`,
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		graph, err := peekr.BuildImportGraph(ctx, opts, peekr.ImportGraphOptions{Patterns: args, External: ImportsExternal})
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		if err := peekr.WriteImportGraph(os.Stdout, graph, ImportsFormat); err != nil {
+			return err
+		}
+		peekr.FprintDiagnostics(os.Stderr, graph.Diagnostics)
+		if len(graph.Cycles) > 0 {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importsCmd)
+
+	importsCmd.Flags().StringVar(&ImportsFormat, "format", "text", "Output format: "+strings.Join(peekr.ImportGraphFormats, ", ")+".")
+	importsCmd.Flags().BoolVar(&ImportsExternal, "external", false, "Include the external modules the packages import.")
+}
@@ -4,6 +4,13 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
 	"github.com/mwiater/peekr/peekr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -11,6 +18,12 @@ import (
 
 var FunctionsOnly bool
 var StructsOnly bool
+var Matrix bool
+var Platforms []string
+var WatchMode bool
+var WatchDiff bool
+var Format string
+var Usage bool
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
@@ -19,23 +32,118 @@ var listCmd = &cobra.Command{
 	Long: `Peek into the source code for a high-level view of how a package
 is constructed. By default, the 'list' command will print both
 functions and structs in the specified package. You can filter
-out one or the other by specifying '-s' and '-f' flags.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		dir := viper.GetString("directory")
-		pkg := viper.GetString("package")
+out one or the other by specifying '-s' and '-f' flags.
+
+With '--matrix', the command instead prints a table showing on which
+platforms each exported symbol exists, according to build constraints.
+'--format json' writes the matrix as JSON instead.
+
+With '--watch', the listing is redrawn whenever a file of the package
+changes. Only the changed files are parsed again, though '--typecheck'
+type-checks the package again on every redraw. Add '--diff' to
+highlight the symbols that changed since the previous redraw.
 
-		// Call ListPackageFunctions if FunctionsOnly is true or if neither FunctionsOnly nor StructsOnly is true.
-		if FunctionsOnly || (!FunctionsOnly && !StructsOnly) {
-			peekr.ListPackageFunctions(dir, pkg)
+'--format' selects the output format: 'text' (the default), 'json' or
+'markdown', plus any renderer registered by code built into peekr.
+
+With '--usage', every symbol and struct field is annotated with the
+number of references to it from all packages of the workspace, which
+type-checks the whole workspace.`,
+	PreRunE: requireScanFlags,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := scanOptions()
+
+		if WatchMode && Matrix {
+			return fmt.Errorf("--watch cannot be combined with --matrix")
+		}
+		if WatchDiff && !WatchMode {
+			return fmt.Errorf("--diff requires --watch")
+		}
+		if Usage && (WatchMode || Matrix) {
+			return fmt.Errorf("--usage cannot be combined with --watch or --matrix")
+		}
+		opts.Usage = Usage
+		renderer, err := peekr.LookupRenderer(Format)
+		if err != nil {
+			return err
 		}
 
-		// Call ListPackageStructs if StructsOnly is true or if neither FunctionsOnly nor StructsOnly is true.
-		if StructsOnly || (!FunctionsOnly && !StructsOnly) {
-			peekr.ListPackageStructs(dir, pkg)
+		if Matrix {
+			if !helpers.SliceContains(peekr.MatrixFormats, Format) {
+				return fmt.Errorf("--matrix only supports the formats %s", strings.Join(peekr.MatrixFormats, ", "))
+			}
+			var platforms []peekr.Platform
+			for _, value := range Platforms {
+				platform, err := peekr.ParsePlatform(value)
+				if err != nil {
+					return fmt.Errorf("invalid --platforms value: %w", err)
+				}
+				platforms = append(platforms, platform)
+			}
+			ctx, cancel := scanContext(cmd)
+			defer cancel()
+			matrix, err := peekr.BuildPlatformMatrix(ctx, opts.Dir, opts.Package, opts, platforms)
+			if err != nil {
+				exitOnScanError(err)
+			}
+			if err := peekr.WritePlatformMatrix(os.Stdout, opts.Package, matrix, Format); err != nil {
+				return err
+			}
+			if Format == peekr.DefaultFormat {
+				peekr.PrintDiagnostics(matrix.Diagnostics)
+			} else {
+				peekr.FprintDiagnostics(os.Stderr, matrix.Diagnostics)
+			}
+			return nil
+		}
+
+		// List functions if FunctionsOnly is true or if neither FunctionsOnly nor StructsOnly is true,
+		// and structs if StructsOnly is true or if neither is true. Both come from a single load.
+		renderOpts := peekr.RenderOptions{
+			Functions: FunctionsOnly || (!FunctionsOnly && !StructsOnly),
+			Structs:   StructsOnly || (!FunctionsOnly && !StructsOnly),
+		}
+		if WatchMode {
+			return watchPackage(opts, renderer, renderOpts)
 		}
+
+		pkg := loadPackage(cmd, opts)
+		return renderer.Render(os.Stdout, pkg, renderOpts)
 	},
 }
 
+// watchPackage redraws the listing every time the package changes, until
+// the user interrupts it.
+// With a format other than text, each redraw is appended to the output
+// instead of replacing the screen.
+func watchPackage(opts peekr.Options, renderer peekr.Renderer, renderOpts peekr.RenderOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	text := Format == peekr.DefaultFormat
+	return peekr.Watch(ctx, opts.Dir, opts.Package, opts, func(p *peekr.Package, diff *peekr.SymbolDiff, err error) {
+		if text {
+			// Clear the screen and move the cursor home before redrawing.
+			fmt.Print("\033[H\033[2J")
+		}
+		if err != nil {
+			peekr.PrintError(err)
+			return
+		}
+		renderOpts.Diff = nil
+		if WatchDiff {
+			renderOpts.Diff = diff
+		}
+		if err := renderer.Render(os.Stdout, p, renderOpts); err != nil {
+			peekr.PrintError(err)
+			return
+		}
+		if text {
+			fmt.Println("Watching for changes. Press Ctrl+C to stop.")
+		}
+	})
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
@@ -45,4 +153,11 @@ func init() {
 
 	listCmd.Flags().BoolVarP(&StructsOnly, "structs", "s", false, "Only list package structs.")
 	viper.BindPFlag("structs", rootCmd.PersistentFlags().Lookup("structs"))
+
+	listCmd.Flags().BoolVar(&Matrix, "matrix", false, "Show which symbols exist for which platforms.")
+	listCmd.Flags().StringSliceVar(&Platforms, "platforms", nil, "Comma-separated goos/goarch targets for --matrix (default: common platforms).")
+	listCmd.Flags().BoolVar(&WatchMode, "watch", false, "Redraw the listing whenever a file of the package changes.")
+	listCmd.Flags().StringVar(&Format, "format", peekr.DefaultFormat, "Output format: "+strings.Join(peekr.RendererNames(), ", ")+".")
+	listCmd.Flags().BoolVar(&WatchDiff, "diff", false, "With --watch, highlight the symbols that changed since the last redraw.")
+	listCmd.Flags().BoolVar(&Usage, "usage", false, "Annotate every symbol with the number of references to it across the workspace.")
 }
@@ -0,0 +1,54 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+// pluginsOnce makes sure plugins are only looked up once, whether the
+// commands are first needed by main or by Execute.
+var pluginsOnce sync.Once
+
+// addPluginCommands adds a subcommand for every peekr-<name> executable on
+// PATH. Built-in commands always take precedence over plugins of the same name.
+func addPluginCommands() {
+	pluginsOnce.Do(func() {
+		for _, plugin := range peekr.FindPlugins(os.Getenv("PATH")) {
+			if cmd, _, err := rootCmd.Find([]string{plugin.Name}); err == nil && cmd != rootCmd {
+				continue
+			}
+			rootCmd.AddCommand(newPluginCommand(plugin))
+		}
+	})
+}
+
+// newPluginCommand returns the command that loads the package selected by
+// the global flags and runs plugin with it.
+func newPluginCommand(plugin peekr.Plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:   plugin.Name,
+		Short: fmt.Sprintf("Run the %s%s plugin.", peekr.PluginPrefix, plugin.Name),
+		Long: fmt.Sprintf(`Load the package selected by '-d' and '-p' and run the plugin
+%s with it. The package is written to the plugin's stdin as
+JSON (protocol version %d). Arguments after '--' are passed to the plugin.`, plugin.Path, peekr.PluginProtocolVersion),
+		Args:    cobra.ArbitraryArgs,
+		PreRunE: requireScanFlags,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkg := loadPackage(cmd, scanOptions())
+			err := peekr.RunPlugin(cmd.Context(), plugin, pkg, args, os.Stdout, os.Stderr)
+			var pluginErr *peekr.PluginError
+			if errors.As(err, &pluginErr) {
+				os.Exit(pluginErr.ExitCode)
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mwiater/peekr/peekr"
+	"golang.org/x/term"
+)
+
+// progressDelay keeps the indicator hidden for quick scans, so it only shows
+// up on trees large enough to keep the user waiting.
+const progressDelay = 300 * time.Millisecond
+
+// progressInterval limits how often the indicator is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// progressIndicator draws a single, continuously updated status line on a
+// terminal while a package is loaded.
+type progressIndicator struct {
+	w          io.Writer
+	start      time.Time
+	lastDraw   time.Time
+	drawn      bool
+	discovered int
+	parsed     int
+	failed     int
+	total      int
+}
+
+// newProgressIndicator returns an indicator that draws on stderr, or nil when
+// stderr is not a terminal.
+func newProgressIndicator() *progressIndicator {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return &progressIndicator{w: os.Stderr, start: time.Now()}
+}
+
+// attach makes opts report progress to the indicator. A nil indicator
+// leaves opts unchanged.
+func (p *progressIndicator) attach(opts *peekr.Options) {
+	if p != nil {
+		opts.Progress = p.update
+	}
+}
+
+// update records a progress event and redraws the status line when due.
+func (p *progressIndicator) update(ev peekr.ProgressEvent) {
+	switch ev.Kind {
+	case peekr.ProgressDiscovered:
+		p.discovered = ev.Count
+	case peekr.ProgressParsed:
+		p.parsed++
+	case peekr.ProgressFailed:
+		p.failed++
+	}
+	p.total = ev.Total
+
+	now := time.Now()
+	if now.Sub(p.start) < progressDelay || now.Sub(p.lastDraw) < progressInterval {
+		return
+	}
+	p.lastDraw = now
+	p.drawn = true
+
+	if p.total == 0 {
+		fmt.Fprintf(p.w, "\r\033[KDiscovering files: %d found", p.discovered)
+		return
+	}
+	fmt.Fprintf(p.w, "\r\033[KParsing files: %d/%d", p.parsed+p.failed, p.total)
+	if p.failed > 0 {
+		fmt.Fprintf(p.w, " (%d failed)", p.failed)
+	}
+}
+
+// finish erases the status line, if it was ever drawn.
+func (p *progressIndicator) finish() {
+	if p != nil && p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
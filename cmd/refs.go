@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+// refsCmd represents the refs command
+var refsCmd = &cobra.Command{
+	Use:   "refs",
+	Short: "List every reference to a symbol across the workspace.",
+	Long: `Type-check every package of the workspace in '-d' and list every
+call site and other reference to the named symbol, with its position
+and enclosing function. Name the symbol as 'pkg.Name', or
+'pkg.Type.Name' for a method or field, where pkg is a package name or
+import path, e.g. 'peekr refs helpers.TerminalColor'.
+
+Packages are checked for the host platform unless '--goos' or
+'--goarch' is set. Add '--tests' to include references from tests.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		result, err := peekr.FindReferences(ctx, opts, args[0])
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		peekr.PrintReferences(result)
+		peekr.PrintDiagnostics(result.Diagnostics)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(refsCmd)
+}
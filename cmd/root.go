@@ -4,26 +4,50 @@ Copyright © 2023 Matt J. Wiater
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/mwiater/peekr/peekr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var Directory string
 var Package string
+var Vendor bool
+var NoWorkspace bool
+var GOOS string
+var GOARCH string
+var Tags []string
+var Tests bool
+var ExcludeGenerated bool
+var OnlyGenerated bool
+var GitIgnore bool
+var NoIgnore bool
+var Include []string
+var Exclude []string
+var Workers int
+var NoCache bool
+var Timeout time.Duration
+var TypeCheck bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "peekr",
-	Short: "Peek under the hood",
-	Long:  `The Peekr command by itself doesn't do anything at the moment. Please
+	Use:     "peekr",
+	Version: peekr.Version,
+	Short:   "Peek under the hood",
+	Long: `The Peekr command by itself doesn't do anything at the moment. Please
 see the Peekr list subcommand via: 'peekr list --help'`,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	addPluginCommands()
 	err := rootCmd.Execute()
 	if err != nil {
 		// TO DO
@@ -34,17 +58,161 @@ func Execute() {
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVarP(&Directory, "directory", "d", "", "Absolute path of directory to scan.")
-	rootCmd.MarkPersistentFlagRequired("directory")
 	viper.BindPFlag("directory", rootCmd.PersistentFlags().Lookup("directory"))
 
-	rootCmd.PersistentFlags().StringVarP(&Package, "package", "p", "", "Name of package to scan.")
-	rootCmd.MarkPersistentFlagRequired("package")
+	rootCmd.PersistentFlags().StringVarP(&Package, "package", "p", "", "Name or import path of package to scan.")
 	viper.BindPFlag("package", rootCmd.PersistentFlags().Lookup("package"))
+
+	rootCmd.PersistentFlags().BoolVar(&Vendor, "vendor", false, "Include vendored packages, honoring vendor/modules.txt like -mod=vendor.")
+	viper.BindPFlag("vendor", rootCmd.PersistentFlags().Lookup("vendor"))
+
+	rootCmd.PersistentFlags().BoolVar(&NoWorkspace, "no-workspace", false, "Ignore go.work and scan only the given directory.")
+	viper.BindPFlag("no-workspace", rootCmd.PersistentFlags().Lookup("no-workspace"))
+
+	rootCmd.PersistentFlags().StringVar(&GOOS, "goos", "", "Only read files built for this GOOS (defaults to the host when --goarch or --tags is set).")
+	viper.BindPFlag("goos", rootCmd.PersistentFlags().Lookup("goos"))
+
+	rootCmd.PersistentFlags().StringVar(&GOARCH, "goarch", "", "Only read files built for this GOARCH (defaults to the host when --goos or --tags is set).")
+	viper.BindPFlag("goarch", rootCmd.PersistentFlags().Lookup("goarch"))
+
+	rootCmd.PersistentFlags().StringSliceVar(&Tags, "tags", nil, "Comma-separated build tags to satisfy when evaluating build constraints.")
+	viper.BindPFlag("tags", rootCmd.PersistentFlags().Lookup("tags"))
+
+	rootCmd.PersistentFlags().BoolVar(&Tests, "tests", false, "Include _test.go files and the external pkg_test package.")
+	viper.BindPFlag("tests", rootCmd.PersistentFlags().Lookup("tests"))
+
+	rootCmd.PersistentFlags().BoolVar(&ExcludeGenerated, "exclude-generated", false, "Skip files marked '// Code generated ... DO NOT EDIT.'.")
+	viper.BindPFlag("exclude-generated", rootCmd.PersistentFlags().Lookup("exclude-generated"))
+
+	rootCmd.PersistentFlags().BoolVar(&OnlyGenerated, "only-generated", false, "Only read files marked '// Code generated ... DO NOT EDIT.'.")
+	viper.BindPFlag("only-generated", rootCmd.PersistentFlags().Lookup("only-generated"))
+	rootCmd.MarkFlagsMutuallyExclusive("exclude-generated", "only-generated")
+
+	rootCmd.PersistentFlags().BoolVar(&GitIgnore, "gitignore", false, "Honor .gitignore files in addition to .peekrignore.")
+	viper.BindPFlag("gitignore", rootCmd.PersistentFlags().Lookup("gitignore"))
+
+	rootCmd.PersistentFlags().BoolVar(&NoIgnore, "no-ignore", false, "Disable .peekrignore, .gitignore and the built-in directory ignore rules.")
+	viper.BindPFlag("no-ignore", rootCmd.PersistentFlags().Lookup("no-ignore"))
+
+	rootCmd.PersistentFlags().StringSliceVar(&Include, "include", nil, "Only read files matching these gitignore-style globs.")
+	viper.BindPFlag("include", rootCmd.PersistentFlags().Lookup("include"))
+
+	rootCmd.PersistentFlags().StringSliceVar(&Exclude, "exclude", nil, "Skip files and directories matching these gitignore-style globs.")
+	viper.BindPFlag("exclude", rootCmd.PersistentFlags().Lookup("exclude"))
+
+	rootCmd.PersistentFlags().IntVar(&Workers, "workers", 0, "Number of files to parse concurrently (default: one per CPU).")
+	viper.BindPFlag("workers", rootCmd.PersistentFlags().Lookup("workers"))
+
+	rootCmd.PersistentFlags().BoolVar(&NoCache, "no-cache", false, "Do not read or write the on-disk extraction cache.")
+	viper.BindPFlag("no-cache", rootCmd.PersistentFlags().Lookup("no-cache"))
+
+	rootCmd.PersistentFlags().DurationVar(&Timeout, "timeout", 0, "Give up scanning after this long, e.g. 30s (default: no limit).")
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+
+	rootCmd.PersistentFlags().BoolVar(&TypeCheck, "typecheck", false, "Type-check the package with go/types and show resolved types (slower; for the host platform unless --goos/--goarch is set).")
+	viper.BindPFlag("typecheck", rootCmd.PersistentFlags().Lookup("typecheck"))
+}
+
+// requireScanFlags is used as PreRunE by every command that scans a package,
+// making the global --directory and --package flags mandatory for them while
+// leaving commands such as 'cache clean' usable without them.
+func requireScanFlags(cmd *cobra.Command, args []string) error {
+	return requireFlags(cmd, "directory", "package")
+}
+
+// requireDirectoryFlag is used as PreRunE by commands that scan the whole
+// workspace rather than a single package.
+func requireDirectoryFlag(cmd *cobra.Command, args []string) error {
+	return requireFlags(cmd, "directory")
+}
+
+// requireFlags returns an error naming every flag in names that was not set.
+func requireFlags(cmd *cobra.Command, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if !cmd.Flags().Changed(name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf(`required flag(s) "%s" not set`, strings.Join(missing, `", "`))
+	}
+	return nil
+}
+
+// scanOptions builds the peekr.Options shared by every subcommand from the global flags.
+func scanOptions() peekr.Options {
+	generated := peekr.IncludeGenerated
+	switch {
+	case viper.GetBool("exclude-generated"):
+		generated = peekr.ExcludeGenerated
+	case viper.GetBool("only-generated"):
+		generated = peekr.OnlyGenerated
+	}
+
+	return peekr.Options{
+		Dir:         viper.GetString("directory"),
+		Package:     viper.GetString("package"),
+		Vendor:      viper.GetBool("vendor"),
+		NoWorkspace: viper.GetBool("no-workspace"),
+		GOOS:        viper.GetString("goos"),
+		GOARCH:      viper.GetString("goarch"),
+		Tags:        viper.GetStringSlice("tags"),
+		Tests:       viper.GetBool("tests"),
+		Generated:   generated,
+		GitIgnore:   viper.GetBool("gitignore"),
+		NoIgnore:    viper.GetBool("no-ignore"),
+		Include:     viper.GetStringSlice("include"),
+		Exclude:     viper.GetStringSlice("exclude"),
+		Workers:     viper.GetInt("workers"),
+		Cache:       !viper.GetBool("no-cache"),
+		TypeCheck:   viper.GetBool("typecheck"),
+	}
+}
+
+// scanContext returns the context scans of cmd run under, limited by --timeout.
+func scanContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		return context.WithTimeout(cmd.Context(), timeout)
+	}
+	return context.WithCancel(cmd.Context())
+}
+
+// loadPackage loads the package selected by opts for cmd, showing a progress
+// indicator on terminals, and exits if it cannot be loaded.
+func loadPackage(cmd *cobra.Command, opts peekr.Options) *peekr.Package {
+	ctx, cancel := scanContext(cmd)
+	defer cancel()
+
+	progress := newProgressIndicator()
+	progress.attach(&opts)
+	pkg, err := peekr.Load(ctx, opts)
+	progress.finish()
+	if err != nil {
+		exitOnScanError(err)
+	}
+	return pkg
+}
+
+// exitOnScanError reports an error that prevented a scan and exits. A missing
+// package exits with peekr.ExitPackageNotFound; anything else exits with status 1.
+func exitOnScanError(err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("scan timed out after %s: %w", viper.GetDuration("timeout"), err)
+	}
+	peekr.PrintError(err)
+
+	var notFound *peekr.PackageNotFoundError
+	if errors.As(err, &notFound) {
+		os.Exit(peekr.ExitPackageNotFound)
+	}
+	os.Exit(1)
 }
 
 // ListAllCobraCommands prints all commands and subcommands recursively
 func ListAllCobraCommands(cmd *cobra.Command) []string {
 	var commands []string
+	addPluginCommands()
 	// Get a list of child commands
 	subcommands := rootCmd.Commands()
 	// Recursively print each subcommand
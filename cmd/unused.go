@@ -0,0 +1,63 @@
+/*
+Copyright © 2023 Matt J. Wiater
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/mwiater/peekr/peekr"
+	"github.com/spf13/cobra"
+)
+
+var Consumers []string
+var Allow []string
+
+// unusedCmd represents the unused command
+var unusedCmd = &cobra.Command{
+	Use:   "unused",
+	Short: "List exported symbols that nothing references.",
+	Long: `Type-check every package of the workspace in '-d' and list the
+exported functions, methods, types, struct fields, constants and
+variables that no package references. Main packages are skipped, and
+so are methods that implement an interface and struct fields with tags.
+
+'--consumer' adds the packages of another module on disk, such as a
+service importing this one, whose references count as uses. References
+from tests only count with '--tests'.
+
+Symbols that are intentionally part of the public API can be listed in
+a '` + peekr.AllowlistFileName + `' file next to go.mod, one pattern per line, or with
+'--allow'. Patterns such as 'helpers.TerminalInfo' or 'peekr.Options.*'
+match the short or import-path-qualified name of a symbol.
+
+The command exits with status 1 when unused symbols are found.`,
+	Args:    cobra.NoArgs,
+	PreRunE: requireDirectoryFlag,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := scanContext(cmd)
+		defer cancel()
+
+		opts := scanOptions()
+		progress := newProgressIndicator()
+		progress.attach(&opts)
+		report, err := peekr.FindUnused(ctx, opts, peekr.UnusedOptions{Consumers: Consumers, Allow: Allow})
+		progress.finish()
+		if err != nil {
+			exitOnScanError(err)
+		}
+		peekr.PrintUnused(report)
+		peekr.PrintDiagnostics(report.Diagnostics)
+		if len(report.Symbols) > 0 {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unusedCmd)
+
+	unusedCmd.Flags().StringSliceVar(&Consumers, "consumer", nil, "Directories of other modules whose references count as uses.")
+	unusedCmd.Flags().StringSliceVar(&Allow, "allow", nil, "Patterns of symbols that are intentionally part of the public API.")
+}
@@ -3,6 +3,7 @@ package helpers
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
@@ -59,7 +60,12 @@ var colorMap = map[ErrorLevel]string{
 }
 
 // ClearTerminal clears the terminal screen based on the operating system.
+// It does nothing when stdout is not a terminal, so redirected output is not
+// polluted with escape sequences.
 func ClearTerminal() error {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
 	case "windows":
@@ -107,10 +113,15 @@ func TerminalInfo() (*Terminal, error) {
 
 // TerminalColor prints the given string to the terminal in the color corresponding to the error level
 func TerminalColor(message string, level ErrorLevel) {
+	FprintColor(os.Stdout, message, level)
+}
+
+// FprintColor writes the given string and a newline to w in the color corresponding to the error level
+func FprintColor(w io.Writer, message string, level ErrorLevel) {
 	colorCode, ok := colorMap[level]
 	if !ok {
-		fmt.Println(message)
+		fmt.Fprintln(w, message)
 		return
 	}
-	fmt.Printf("%s%s\033[0m\n", colorCode, message)
+	fmt.Fprintf(w, "%s%s\033[0m\n", colorCode, message)
 }
@@ -23,7 +23,11 @@ func main() {
 	} else {
 		helpers.ClearTerminal()
 
-		peekr.ListPackageFunctions("/home/matt/projects/golangpeekr", "helpers")
-		peekr.ListPackageStructs("/home/matt/projects/golangpeekr", "helpers")
+		diags, err := peekr.ListPackage("/home/matt/projects/golangpeekr", "helpers", peekr.Options{}, true, true)
+		peekr.PrintDiagnostics(diags)
+		if err != nil {
+			peekr.PrintError(err)
+			os.Exit(1)
+		}
 	}
 }
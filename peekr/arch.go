@@ -0,0 +1,274 @@
+package peekr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// ArchFileName is the name of the file declaring the architecture rules of
+// a module, read by CheckArchitecture. It sits next to go.mod and holds one
+// rule per line; blank lines and lines starting with "#" are ignored. A rule
+// is either
+//
+//	<packages> must not import <package> [<package>...]
+//	<packages> may only import <package> [<package>...]
+//
+// where every package is a pattern naming workspace packages by import path
+// relative to their module ("cmd", "internal/store", "." for the module
+// root) or in full ("github.com/mwiater/peekr/cmd"). A "/..." suffix also
+// matches the packages below, and "..." alone matches every package.
+const ArchFileName = ".peekrarch"
+
+// ArchRule is a single layering rule.
+type ArchRule struct {
+	Packages string   `json:"packages"` // Pattern of the packages the rule applies to
+	Only     bool     `json:"only"`     // "may only import" rather than "must not import"
+	Imports  []string `json:"imports"`  // Patterns of the packages listed by the rule
+	File     string   `json:"file"`     // Where the rule was declared
+	Line     int      `json:"line"`
+}
+
+// String formats the rule the way it is written in an ArchFileName file.
+func (r ArchRule) String() string {
+	verb := "must not import"
+	if r.Only {
+		verb = "may only import"
+	}
+	return r.Packages + " " + verb + " " + strings.Join(r.Imports, " ")
+}
+
+// ArchViolation is an import that breaks a rule.
+type ArchViolation struct {
+	Rule ArchRule `json:"rule"`
+	From string   `json:"from"` // Import path of the importing package
+	To   string   `json:"to"`   // Import path of the imported package
+	File string   `json:"file"` // Position of the import declaration
+	Line int      `json:"line"`
+}
+
+// ArchUnmatched is a pattern of a rule that names no workspace package,
+// usually a typo that would otherwise make the rule pass forever.
+type ArchUnmatched struct {
+	Rule    ArchRule `json:"rule"`
+	Pattern string   `json:"pattern"`
+}
+
+// ArchReport is the result of CheckArchitecture.
+type ArchReport struct {
+	Rules       []ArchRule      `json:"rules"`
+	Violations  []ArchViolation `json:"violations"`          // Sorted by file and line
+	Unmatched   []ArchUnmatched `json:"unmatched,omitempty"` // In rule order
+	Diagnostics []Diagnostic    `json:"diagnostics,omitempty"`
+}
+
+// Failed reports whether an import breaks a rule or a rule names a package
+// that does not exist.
+func (r *ArchReport) Failed() bool {
+	return len(r.Violations) > 0 || len(r.Unmatched) > 0
+}
+
+// ReadArchRules parses an ArchFileName file. Malformed rules are reported
+// as an error naming their line.
+func ReadArchRules(path string) ([]ArchRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadArchRules(): %w", err)
+	}
+	defer f.Close()
+
+	var rules []ArchRule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, ok := parseArchRule(text)
+		if !ok {
+			return nil, fmt.Errorf(`ReadArchRules(): %s:%d: expected "<packages> must not import <packages>" or "<packages> may only import <packages>", got %q`, path, line, text)
+		}
+		rule.File, rule.Line = path, line
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ReadArchRules(): %w", err)
+	}
+	return rules, nil
+}
+
+// parseArchRule parses the text of a single rule.
+func parseArchRule(text string) (ArchRule, bool) {
+	fields := strings.Fields(text)
+	if len(fields) < 5 || fields[3] != "import" {
+		return ArchRule{}, false
+	}
+	rule := ArchRule{Packages: fields[0], Imports: fields[4:]}
+	switch fields[1] + " " + fields[2] {
+	case "must not":
+	case "may only":
+		rule.Only = true
+	default:
+		return ArchRule{}, false
+	}
+	// Commas are accepted between packages, as in "peekr, config".
+	var imports []string
+	for _, field := range rule.Imports {
+		for _, pattern := range strings.Split(field, ",") {
+			if pattern != "" && pattern != "and" {
+				imports = append(imports, pattern)
+			}
+		}
+	}
+	rule.Imports = imports
+	return rule, len(imports) > 0
+}
+
+// findArchRules returns the ArchFileName file of opts.Dir or, failing that,
+// of the first workspace module that has one.
+func findArchRules(ws *Workspace) (string, error) {
+	dirs := []string{ws.Dir}
+	for _, mod := range ws.Modules {
+		dirs = append(dirs, mod.Dir)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, ArchFileName)
+		if isFile(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("findArchRules(): no %s file found in %s or its modules", ArchFileName, ws.Dir)
+}
+
+// CheckArchitecture parses the workspace containing opts.Dir and checks
+// every import of one workspace package by another against the rules of
+// rulesPath, or of the ArchFileName file of opts.Dir or its workspace
+// modules when rulesPath is empty. Imports of the standard library and of
+// external modules are never checked. Patterns of a rule that match no
+// workspace package are reported in ArchReport.Unmatched.
+func CheckArchitecture(ctx context.Context, opts Options, rulesPath string) (*ArchReport, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("CheckArchitecture(): Options.Dir is required")
+	}
+	scan, err := scanImports(ctx, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rulesPath == "" {
+		if rulesPath, err = findArchRules(scan.ws); err != nil {
+			return nil, err
+		}
+	}
+	rules, err := ReadArchRules(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ArchReport{Rules: rules, Diagnostics: scan.diagnostics}
+	for _, rule := range rules {
+		for _, pattern := range append([]string{rule.Packages}, rule.Imports...) {
+			if !scan.matchesAnyPackage(pattern) {
+				report.Unmatched = append(report.Unmatched, ArchUnmatched{Rule: rule, Pattern: pattern})
+			}
+		}
+	}
+	for _, site := range scan.sites {
+		if _, ok := scan.ws.ModuleFor(site.to); !ok {
+			continue
+		}
+		from := strings.TrimSuffix(site.from, "_test")
+		for _, rule := range rules {
+			if !scan.ws.matchesArchPattern(rule.Packages, from) {
+				continue
+			}
+			listed := false
+			for _, pattern := range rule.Imports {
+				listed = listed || scan.ws.matchesArchPattern(pattern, site.to)
+			}
+			// A package may always import itself from its external tests.
+			if listed != rule.Only && site.to != from {
+				report.Violations = append(report.Violations, ArchViolation{Rule: rule, From: site.from, To: site.to, File: site.file, Line: site.line})
+			}
+		}
+	}
+
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		a, b := report.Violations[i], report.Violations[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return report, nil
+}
+
+// matchesAnyPackage reports whether an ArchFileName pattern names at least
+// one of the scanned packages.
+func (scan *importScan) matchesAnyPackage(pattern string) bool {
+	for path := range scan.packages {
+		if scan.ws.matchesArchPattern(pattern, strings.TrimSuffix(path, "_test")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesArchPattern reports whether an ArchFileName pattern names the
+// workspace package with the given import path.
+func (ws *Workspace) matchesArchPattern(pattern, importPath string) bool {
+	names := []string{importPath}
+	if mod, ok := ws.ModuleFor(importPath); ok {
+		rel := strings.TrimPrefix(strings.TrimPrefix(importPath, mod.Path), "/")
+		if rel == "" {
+			rel = "."
+		}
+		names = append(names, rel)
+	}
+
+	recursive := pattern == "..." || strings.HasSuffix(pattern, "/...")
+	prefix := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+	for _, name := range names {
+		if name == prefix || (recursive && (prefix == "" || prefix == "." || strings.HasPrefix(name, prefix+"/"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintArchReport prints the result of CheckArchitecture.
+func PrintArchReport(report *ArchReport) {
+	writeArchReport(os.Stdout, report)
+}
+
+// writeArchReport writes every unmatched pattern and every violation of
+// report to w, with the position of the offending import and the rule it
+// breaks.
+func writeArchReport(w io.Writer, report *ArchReport) {
+	if len(report.Unmatched) > 0 {
+		header := fmt.Sprintf("\n%d rule pattern(s) match no package of the workspace:\n", len(report.Unmatched))
+		helpers.FprintColor(w, header, helpers.Error)
+		for _, u := range report.Unmatched {
+			helpers.FprintColor(w, fmt.Sprintf("  %s:%d: %q in %q", u.Rule.File, u.Rule.Line, u.Pattern, u.Rule.String()), helpers.Error)
+		}
+	}
+	if len(report.Violations) == 0 {
+		if !report.Failed() {
+			helpers.FprintColor(w, fmt.Sprintf("\nAll imports follow the %d architecture rule(s).", len(report.Rules)), helpers.Debug)
+		}
+		return
+	}
+	header := fmt.Sprintf("\n%d import(s) break the architecture rules:\n", len(report.Violations))
+	helpers.FprintColor(w, header, helpers.Error)
+	for _, v := range report.Violations {
+		helpers.FprintColor(w, fmt.Sprintf("  %s:%d: %s imports %s", v.File, v.Line, v.From, v.To), helpers.Error)
+		helpers.FprintColor(w, fmt.Sprintf("    breaks %q (%s:%d)", v.Rule.String(), v.Rule.File, v.Rule.Line), helpers.Warn)
+	}
+}
@@ -0,0 +1,74 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckArchitecture(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":             "module example.com/arch\n",
+		"helpers/helpers.go": "package helpers\n\nimport \"example.com/arch/core\"\n\nvar H = core.C\n",
+		"core/core.go":       "package core\n\nimport \"fmt\"\n\nvar C = fmt.Sprint()\n",
+		"core/store/s.go":    "package store\n",
+		"cmd/cmd.go":         "package cmd\n\nimport (\n\t\"example.com/arch/core\"\n\t\"example.com/arch/core/store\"\n\t\"example.com/arch/helpers\"\n)\n\nvar _, _ = core.C, helpers.H\nvar _ store.S\n",
+		ArchFileName:         "# layers\nhelpers must not import core/...\ncmd may only import core, helpers\n",
+	})
+
+	report, err := CheckArchitecture(context.Background(), Options{Dir: root}, "")
+	require.NoError(t, err)
+	require.Len(t, report.Rules, 2)
+	assert.Equal(t, ArchRule{Packages: "cmd", Only: true, Imports: []string{"core", "helpers"}, File: filepath.Join(root, ArchFileName), Line: 3}, report.Rules[1])
+
+	var violations []string
+	for _, v := range report.Violations {
+		violations = append(violations, v.From+" -> "+v.To+": "+v.Rule.String())
+	}
+	assert.Equal(t, []string{
+		"example.com/arch/cmd -> example.com/arch/core/store: cmd may only import core helpers",
+		"example.com/arch/helpers -> example.com/arch/core: helpers must not import core/...",
+	}, violations)
+	assert.Equal(t, 5, report.Violations[0].Line)
+
+	var buf bytes.Buffer
+	writeArchReport(&buf, report)
+	assert.Contains(t, buf.String(), "2 import(s) break the architecture rules")
+
+	assert.Empty(t, report.Unmatched)
+	assert.True(t, report.Failed())
+
+	t.Run("Unmatched patterns", func(t *testing.T) {
+		writeTree(t, root, map[string]string{"typo": "helper must not import core\ncmd must not import core/store, stor\n"})
+		report, err := CheckArchitecture(context.Background(), Options{Dir: root}, filepath.Join(root, "typo"))
+		require.NoError(t, err)
+		require.Len(t, report.Unmatched, 2)
+		assert.Equal(t, "helper", report.Unmatched[0].Pattern)
+		assert.Equal(t, 1, report.Unmatched[0].Rule.Line)
+		assert.Equal(t, "stor", report.Unmatched[1].Pattern)
+		assert.True(t, report.Failed())
+
+		var buf bytes.Buffer
+		writeArchReport(&buf, report)
+		assert.Contains(t, buf.String(), "2 rule pattern(s) match no package of the workspace")
+		assert.NotContains(t, buf.String(), "All imports follow")
+	})
+
+	t.Run("Malformed rules", func(t *testing.T) {
+		writeTree(t, root, map[string]string{"bad": "cmd should import core\n"})
+		_, err := CheckArchitecture(context.Background(), Options{Dir: root}, filepath.Join(root, "bad"))
+		assert.ErrorContains(t, err, "bad:1")
+	})
+
+	t.Run("Missing rules", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{"go.mod": "module example.com/none\n", "a/a.go": "package a\n"})
+		_, err := CheckArchitecture(context.Background(), Options{Dir: dir}, "")
+		assert.ErrorContains(t, err, ArchFileName)
+	})
+}
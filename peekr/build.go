@@ -0,0 +1,86 @@
+package peekr
+
+import (
+	"fmt"
+	"go/build"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Platform is a GOOS/GOARCH pair that source files can be evaluated against.
+type Platform struct {
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+}
+
+// String returns the platform in the familiar "goos/goarch" form.
+func (p Platform) String() string {
+	return p.GOOS + "/" + p.GOARCH
+}
+
+// DefaultPlatforms is the set of targets used by the platform matrix when
+// none are given explicitly.
+var DefaultPlatforms = []Platform{
+	{GOOS: "linux", GOARCH: "amd64"},
+	{GOOS: "linux", GOARCH: "arm64"},
+	{GOOS: "darwin", GOARCH: "amd64"},
+	{GOOS: "darwin", GOARCH: "arm64"},
+	{GOOS: "windows", GOARCH: "amd64"},
+	{GOOS: "freebsd", GOARCH: "amd64"},
+}
+
+// ParsePlatform parses a "goos/goarch" string such as "linux/amd64".
+func ParsePlatform(s string) (Platform, error) {
+	goos, goarch, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || goos == "" || goarch == "" {
+		return Platform{}, fmt.Errorf("ParsePlatform(): %q is not of the form goos/goarch", s)
+	}
+	return Platform{GOOS: goos, GOARCH: goarch}, nil
+}
+
+// hasBuildTarget reports whether the options select a build target at all.
+func (opts Options) hasBuildTarget() bool {
+	return opts.GOOS != "" || opts.GOARCH != "" || len(opts.Tags) > 0
+}
+
+// buildContext returns a go/build context for the target selected by opts.
+// Cgo is only considered enabled when targeting the host platform, matching
+// the go command's default for cross compilation.
+func (opts Options) buildContext() build.Context {
+	ctxt := build.Default
+	ctxt.GOOS = opts.GOOS
+	if ctxt.GOOS == "" {
+		ctxt.GOOS = runtime.GOOS
+	}
+	ctxt.GOARCH = opts.GOARCH
+	if ctxt.GOARCH == "" {
+		ctxt.GOARCH = runtime.GOARCH
+	}
+	if ctxt.GOOS != runtime.GOOS || ctxt.GOARCH != runtime.GOARCH {
+		ctxt.CgoEnabled = false
+	}
+	ctxt.BuildTags = opts.Tags
+	return ctxt
+}
+
+// filterBuildConstraints drops the files that are not part of the build for
+// the target selected by opts. Without a target, files are returned unchanged.
+func filterBuildConstraints(files []string, opts Options) ([]string, error) {
+	if !opts.hasBuildTarget() {
+		return files, nil
+	}
+
+	ctxt := opts.buildContext()
+	matched := make([]string, 0, len(files))
+	for _, path := range files {
+		ok, err := ctxt.MatchFile(filepath.Dir(path), filepath.Base(path))
+		if err != nil {
+			return nil, fmt.Errorf("filterBuildConstraints(): %w", err)
+		}
+		if ok {
+			matched = append(matched, path)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,129 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatform(t *testing.T) {
+	p, err := ParsePlatform("windows/amd64")
+	require.NoError(t, err)
+	assert.Equal(t, Platform{GOOS: "windows", GOARCH: "amd64"}, p)
+	assert.Equal(t, "windows/amd64", p.String())
+
+	_, err = ParsePlatform("windows")
+	assert.Error(t, err)
+}
+
+func TestBuildConstraints(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":                "module example.com/plat\n",
+		"plat/common.go":        "package plat\n\nfunc Common() {}\n",
+		"plat/clear_windows.go": "package plat\n\nfunc WindowsOnly() {}\n",
+		"plat/clear_linux.go":   "package plat\n\nfunc LinuxOnly() {}\n",
+		"plat/tagged.go":        "//go:build custom\n\npackage plat\n\nfunc Tagged() {}\n",
+	})
+
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{
+			name:     "No target merges everything",
+			opts:     Options{},
+			expected: []string{"Common", "LinuxOnly", "Tagged", "WindowsOnly"},
+		},
+		{
+			name:     "Windows",
+			opts:     Options{GOOS: "windows", GOARCH: "amd64"},
+			expected: []string{"Common", "WindowsOnly"},
+		},
+		{
+			name:     "Linux with tags",
+			opts:     Options{GOOS: "linux", GOARCH: "amd64", Tags: []string{"custom"}},
+			expected: []string{"Common", "LinuxOnly", "Tagged"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			funcMap, _, err := PackageFunctions(root, "plat", tc.opts)
+			require.NoError(t, err)
+
+			var actual []string
+			for _, functions := range funcMap {
+				for _, fn := range functions {
+					actual = append(actual, fn.Function)
+				}
+			}
+			assert.ElementsMatch(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestBuildPlatformMatrix(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":                "module example.com/plat\n",
+		"plat/common.go":        "package plat\n\nfunc Common() {}\n",
+		"plat/clear_windows.go": "package plat\n\nfunc WindowsOnly() {}\n",
+	})
+
+	platforms := []Platform{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "windows", GOARCH: "amd64"}}
+	matrix, err := BuildPlatformMatrix(context.Background(), root, "plat", Options{}, platforms)
+	require.NoError(t, err)
+	require.Len(t, matrix.Rows, 2)
+
+	assert.Equal(t, "Common", matrix.Rows[0].Name)
+	assert.True(t, matrix.Rows[0].Universal(platforms))
+	assert.Equal(t, "WindowsOnly", matrix.Rows[1].Name)
+	assert.False(t, matrix.Rows[1].Universal(platforms))
+	assert.True(t, matrix.Rows[1].Platforms["windows/amd64"])
+
+	t.Run("Formats", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WritePlatformMatrix(&buf, "plat", matrix, "text"))
+		assert.Contains(t, buf.String(), "Platform matrix for the 'plat' package:")
+		assert.Contains(t, buf.String(), "func WindowsOnly  -            x")
+
+		buf.Reset()
+		require.NoError(t, WritePlatformMatrix(&buf, "plat", matrix, "json"))
+		var decoded PlatformMatrix
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, *matrix, decoded)
+		assert.Contains(t, buf.String(), `"goos": "windows"`)
+
+		assert.Error(t, WritePlatformMatrix(&buf, "plat", matrix, "markdown"))
+	})
+
+	t.Run("Methods with the same name", func(t *testing.T) {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{
+			"go.mod":            "module example.com/close\n",
+			"plat/x.go":         "package plat\n\ntype X struct{}\n\nfunc (X) Close() {}\n",
+			"plat/y.go":         "package plat\n\ntype Y struct{}\n",
+			"plat/y_windows.go": "package plat\n\nfunc (*Y) Close() {}\n",
+		})
+		matrix, err := BuildPlatformMatrix(context.Background(), root, "plat", Options{}, platforms)
+		require.NoError(t, err)
+
+		var closers []MatrixRow
+		for _, row := range matrix.Rows {
+			if row.Name == "Close" {
+				closers = append(closers, row)
+			}
+		}
+		require.Len(t, closers, 2)
+		assert.Equal(t, "*Y", closers[0].Receiver)
+		assert.False(t, closers[0].Universal(platforms))
+		assert.Equal(t, "X", closers[1].Receiver)
+		assert.True(t, closers[1].Universal(platforms))
+	})
+}
@@ -0,0 +1,216 @@
+package peekr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CacheDirEnv names the environment variable that overrides the default cache directory.
+const CacheDirEnv = "PEEKR_CACHE_DIR"
+
+// cacheEntry is the on-disk form of a cached package. The entry is only
+// reused when every file still has the recorded content hash.
+type cacheEntry struct {
+	Version string       `json:"version"`
+	Files   []cachedFile `json:"files"`
+	Package *Package     `json:"package"`
+}
+
+// cachedFile records the state of a source file when its package was cached.
+// Size and ModTime let unchanged files skip rehashing; Hash is authoritative.
+type cachedFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+	Hash    string `json:"hash"`
+}
+
+// DefaultCacheDir returns the directory peekr caches extraction results in:
+// $PEEKR_CACHE_DIR if set, otherwise a "peekr" directory inside the user cache directory.
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("DefaultCacheDir(): %w", err)
+	}
+	return filepath.Join(base, "peekr"), nil
+}
+
+// CleanCache removes every cached entry from dir (or the default cache
+// directory when dir is empty), along with temporary files left by
+// interrupted writes, and returns the directory that was cleaned. Other
+// files and the directory itself are left alone, since the cache directory
+// may be shared. A missing directory is not an error.
+func CleanCache(dir string) (string, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dir, nil
+	}
+	if err != nil {
+		return dir, fmt.Errorf("CleanCache(): %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isCacheFile(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return dir, fmt.Errorf("CleanCache(): %w", err)
+		}
+	}
+	return dir, nil
+}
+
+// isCacheFile reports whether name is a file written by writeCache: an
+// entry "<key>.json" or a temporary "<key>.<random>.tmp".
+func isCacheFile(name string) bool {
+	key, rest, _ := strings.Cut(name, ".")
+	if len(key) != sha256.Size*2 || strings.Trim(key, "0123456789abcdef") != "" {
+		return false
+	}
+	return rest == "json" || strings.HasSuffix(rest, ".tmp")
+}
+
+// cacheDir returns the cache directory selected by opts.
+func (opts Options) cacheDir() (string, error) {
+	if opts.CacheDir != "" {
+		return opts.CacheDir, nil
+	}
+	return DefaultCacheDir()
+}
+
+// cacheKey identifies a package load: the peekr version, the request, the
+// options that change what is extracted, and the exact set of files read.
+func cacheKey(dir, pkgName string, opts Options, target scanTarget) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Version   string
+		Dir       string
+		Package   string
+		Tests     bool
+		Generated GeneratedFilter
+		Files     []string
+	}{Version, absDir, pkgName, opts.Tests, opts.Generated, target.files})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readCache returns the cached package for key if every file it was built
+// from still has the same content. Any problem is treated as a cache miss.
+func readCache(opts Options, key string) (*Package, bool) {
+	dir, err := opts.cacheDir()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Version != Version || entry.Package == nil {
+		return nil, false
+	}
+	for _, cached := range entry.Files {
+		current, err := statFile(cached.Path)
+		if err != nil {
+			return nil, false
+		}
+		if current.Size == cached.Size && current.ModTime == cached.ModTime {
+			continue
+		}
+		if current.Hash, err = hashFile(cached.Path); err != nil || current.Hash != cached.Hash {
+			return nil, false
+		}
+	}
+	return entry.Package, true
+}
+
+// writeCache stores pkg under key along with the content hashes of files.
+// The entry is written to a temporary file and renamed into place so
+// concurrent peekr processes never read a partial entry.
+func writeCache(opts Options, key string, files []string, pkg *Package) error {
+	dir, err := opts.cacheDir()
+	if err != nil {
+		return err
+	}
+
+	entry := cacheEntry{Version: Version, Package: pkg}
+	for _, path := range files {
+		cached, err := statFile(path)
+		if err != nil {
+			return err
+		}
+		if cached.Hash, err = hashFile(path); err != nil {
+			return err
+		}
+		entry.Files = append(entry.Files, cached)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("writeCache(): %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("writeCache(): %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writeCache(): %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writeCache(): %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("writeCache(): %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, key+".json"))
+}
+
+// statFile returns the size and modification time of path.
+func statFile(path string) (cachedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return cachedFile{}, err
+	}
+	return cachedFile{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}, nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of the contents of path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,77 @@
+package peekr
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPackageCache(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":     "module example.com/cached\n",
+		"pkg/a.go":   "package pkg\n\nfunc Original() {}\n",
+		"pkg/b.go":   "package pkg\n\ntype Kept struct{ A int }\n",
+		"other/c.go": "package other\n",
+	})
+	opts := Options{Cache: true, CacheDir: cacheDir}
+
+	first, err := LoadPackage(root, "pkg", opts)
+	require.NoError(t, err)
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// Tamper with the cached entry to prove that the next load reads it.
+	data, err := os.ReadFile(entries[0])
+	require.NoError(t, err)
+	var entry cacheEntry
+	require.NoError(t, json.Unmarshal(data, &entry))
+	entry.Package.Name = "from-cache"
+	data, err = json.Marshal(entry)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(entries[0], data, 0o644))
+
+	cached, err := LoadPackage(root, "pkg", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "from-cache", cached.Name)
+	assert.Equal(t, first.Symbols, cached.Symbols)
+
+	uncached, err := LoadPackage(root, "pkg", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "pkg", uncached.Name)
+
+	// Changing a file invalidates the entry.
+	writeTree(t, root, map[string]string{"pkg/a.go": "package pkg\n\nfunc Changed() {}\n"})
+	fresh, err := LoadPackage(root, "pkg", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "pkg", fresh.Name)
+	assert.Equal(t, "Changed", fresh.Symbols[0].Name)
+
+	// Cleaning removes entries and leftover temporary files, but nothing
+	// else the directory holds.
+	stale := strings.TrimSuffix(entries[0], ".json") + ".123.tmp"
+	require.NoError(t, os.WriteFile(stale, nil, 0o644))
+	unrelated := filepath.Join(cacheDir, "notes.json")
+	require.NoError(t, os.WriteFile(unrelated, nil, 0o644))
+
+	dir, err := CleanCache(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, cacheDir, dir)
+	assert.DirExists(t, cacheDir)
+	assert.FileExists(t, unrelated)
+	assert.NoFileExists(t, stale)
+	remaining, err := filepath.Glob(filepath.Join(cacheDir, "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{unrelated}, remaining)
+
+	_, err = CleanCache(filepath.Join(cacheDir, "missing"))
+	assert.NoError(t, err)
+}
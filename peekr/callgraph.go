@@ -0,0 +1,325 @@
+package peekr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Define constants for the kinds of call graph edges.
+const (
+	StaticCall    = "static"    // A call of a package-level function
+	MethodCall    = "method"    // A call of a method of a concrete type, resolved through types
+	InterfaceCall = "interface" // A call through an interface, to one of its possible targets
+)
+
+// CallGraphFormats lists the formats WriteCallGraph supports.
+var CallGraphFormats = []string{"dot", "json"}
+
+// CallGraphOptions controls what BuildCallGraph includes.
+type CallGraphOptions struct {
+	// Module includes the calls made by every package of the workspace
+	// instead of only those of the package selected by Options.Package.
+	Module bool
+
+	// Root, when set, keeps only the functions reachable from the named
+	// function, e.g. "main.main" or "peekr.Options.workers".
+	Root string
+
+	// Depth limits how many calls away from Root functions may be. Zero
+	// means no limit.
+	Depth int
+}
+
+// CallNode is a function or method in a call graph.
+type CallNode struct {
+	ID      string `json:"id"`             // Full name, e.g. "(*example.com/mod/pkg.Options).Load"
+	Name    string `json:"name"`           // Short name, e.g. "(*pkg.Options).Load"
+	Package string `json:"package"`        // Import path of the declaring package
+	File    string `json:"file,omitempty"` // Declaring file; empty when unknown
+	Line    int    `json:"line,omitempty"`
+}
+
+// CallEdge is a call from one function to another. Several calls between
+// the same functions are recorded once, at the first call site.
+type CallEdge struct {
+	Caller string `json:"caller"` // ID of the calling function
+	Callee string `json:"callee"` // ID of the called function
+	Kind   string `json:"kind"`   // StaticCall, MethodCall or InterfaceCall
+	File   string `json:"file"`   // Position of the first call site
+	Line   int    `json:"line"`
+}
+
+// CallGraph is a static call graph. Nodes are sorted by ID and edges by
+// caller, callee and kind.
+type CallGraph struct {
+	Nodes       []CallNode   `json:"nodes"`
+	Edges       []CallEdge   `json:"edges"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// BuildCallGraph type-checks every package of the workspace containing
+// opts.Dir and records the calls made by the functions of the package
+// selected by opts.Package, or of every workspace package with
+// cgOpts.Module. Calls of functions and of methods of concrete types are
+// resolved through the type checker. A call through an interface gets an
+// edge to the method of every workspace type implementing it, or to the
+// interface method itself when none does. Calls of function values cannot
+// be resolved statically and are left out. Calls made inside function
+// literals are attributed to the enclosing function declaration.
+func BuildCallGraph(ctx context.Context, opts Options, cgOpts CallGraphOptions) (*CallGraph, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("BuildCallGraph(): Options.Dir is required")
+	}
+	if opts.Package == "" && !cgOpts.Module {
+		return nil, errors.New("BuildCallGraph(): Options.Package is required unless CallGraphOptions.Module is set")
+	}
+	prog, err := loadProgram(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []*typedPackage
+	if cgOpts.Module {
+		packages = prog.workspacePackages()
+	} else if packages = prog.selectPackages(opts.Package); len(packages) == 0 {
+		return nil, newPackageNotFoundError(opts.Dir, opts.Package, opts)
+	}
+
+	b := &callGraphBuilder{prog: prog, nodes: make(map[string]CallNode), edges: make(map[[3]string]CallEdge), impls: make(map[*types.Func][]*types.Func)}
+	for _, tp := range packages {
+		b.addPackage(tp)
+	}
+
+	graph := &CallGraph{Diagnostics: prog.diagnostics}
+	keep := func(string) bool { return true }
+	if cgOpts.Root != "" {
+		obj, _, err := prog.lookupObject(cgOpts.Root)
+		if err != nil {
+			return nil, err
+		}
+		fn, ok := obj.(*types.Func)
+		if !ok {
+			return nil, fmt.Errorf("BuildCallGraph(): %s is not a function", cgOpts.Root)
+		}
+		reachable := b.reachable(b.node(fn).ID, cgOpts.Depth)
+		keep = func(id string) bool { return reachable[id] }
+	}
+
+	for id, node := range b.nodes {
+		if keep(id) {
+			graph.Nodes = append(graph.Nodes, node)
+		}
+	}
+	for _, edge := range b.edges {
+		if keep(edge.Caller) && keep(edge.Callee) {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.Caller != b.Caller {
+			return a.Caller < b.Caller
+		}
+		if a.Callee != b.Callee {
+			return a.Callee < b.Callee
+		}
+		return a.Kind < b.Kind
+	})
+	return graph, nil
+}
+
+// callGraphBuilder accumulates the nodes and edges of a call graph.
+type callGraphBuilder struct {
+	prog  *program
+	nodes map[string]CallNode
+	edges map[[3]string]CallEdge        // Keyed by caller, callee and kind
+	impls map[*types.Func][]*types.Func // Concrete methods of interface methods
+}
+
+// node returns the node of fn, adding it first if needed.
+func (b *callGraphBuilder) node(fn *types.Func) CallNode {
+	id := fn.FullName()
+	if node, ok := b.nodes[id]; ok {
+		return node
+	}
+	node := CallNode{ID: id, Name: funcDisplayName(fn)}
+	if fn.Pkg() != nil {
+		node.Package = fn.Pkg().Path()
+		pos := b.prog.fset.Position(fn.Pos())
+		node.File, node.Line = pos.Filename, pos.Line
+	}
+	b.nodes[id] = node
+	return node
+}
+
+// addPackage adds the calls made by every function declared in tp.
+func (b *callGraphBuilder) addPackage(tp *typedPackage) {
+	for _, file := range tp.files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			caller, ok := tp.info.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			callerID := b.node(caller).ID
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					b.addCall(tp, callerID, call)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// addCall adds the edges of a single call expression.
+func (b *callGraphBuilder) addCall(tp *typedPackage, callerID string, call *ast.CallExpr) {
+	fun := ast.Unparen(call.Fun)
+	switch index := fun.(type) {
+	case *ast.IndexExpr:
+		fun = index.X
+	case *ast.IndexListExpr:
+		fun = index.X
+	}
+	var ident *ast.Ident
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return
+	}
+	callee, ok := tp.info.Uses[ident].(*types.Func)
+	if !ok {
+		return
+	}
+	callee = callee.Origin()
+	pos := b.prog.fset.Position(call.Pos())
+
+	add := func(target *types.Func, kind string) {
+		edge := CallEdge{Caller: callerID, Callee: b.node(target).ID, Kind: kind, File: pos.Filename, Line: pos.Line}
+		key := [3]string{edge.Caller, edge.Callee, edge.Kind}
+		if _, ok := b.edges[key]; !ok {
+			b.edges[key] = edge
+		}
+	}
+
+	recv := callee.Type().(*types.Signature).Recv()
+	switch {
+	case recv == nil:
+		add(callee, StaticCall)
+	case types.IsInterface(recv.Type()):
+		targets := b.implementations(callee)
+		if len(targets) == 0 {
+			add(callee, InterfaceCall)
+		}
+		for _, target := range targets {
+			add(target, InterfaceCall)
+		}
+	default:
+		add(callee, MethodCall)
+	}
+}
+
+// implementations returns the methods of workspace types that a call of the
+// interface method fn may reach.
+func (b *callGraphBuilder) implementations(fn *types.Func) []*types.Func {
+	if methods, ok := b.impls[fn]; ok {
+		return methods
+	}
+	iface, ok := fn.Type().(*types.Signature).Recv().Type().Underlying().(*types.Interface)
+	var methods []*types.Func
+	if ok && iface.IsMethodSet() {
+		for _, tp := range b.prog.workspacePackages() {
+			for _, obj := range namedTypes(tp.types, false) {
+				if types.IsInterface(obj.Type()) {
+					continue
+				}
+				if _, ok := satisfies(obj.Type(), iface); !ok {
+					continue
+				}
+				method, _, _ := types.LookupFieldOrMethod(obj.Type(), true, fn.Pkg(), fn.Name())
+				if target, ok := method.(*types.Func); ok {
+					methods = append(methods, target)
+				}
+			}
+		}
+	}
+	b.impls[fn] = methods
+	return methods
+}
+
+// reachable returns the IDs of the nodes at most depth calls away from root,
+// or any number of calls when depth is zero.
+func (b *callGraphBuilder) reachable(root string, depth int) map[string]bool {
+	callees := make(map[string][]string)
+	for _, edge := range b.edges {
+		callees[edge.Caller] = append(callees[edge.Caller], edge.Callee)
+	}
+
+	seen := map[string]bool{root: true}
+	frontier := []string{root}
+	for level := 0; len(frontier) > 0 && (depth == 0 || level < depth); level++ {
+		var next []string
+		for _, id := range frontier {
+			for _, callee := range callees[id] {
+				if !seen[callee] {
+					seen[callee] = true
+					next = append(next, callee)
+				}
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
+// WriteCallGraph writes graph to w in one of the CallGraphFormats: "dot"
+// for Graphviz or "json" for the CallGraph itself.
+func WriteCallGraph(w io.Writer, graph *CallGraph, format string) error {
+	switch format {
+	case "dot":
+		return writeCallGraphDOT(w, graph)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	}
+	return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(CallGraphFormats, ", "))
+}
+
+// writeCallGraphDOT writes graph as a Graphviz digraph. Method calls are
+// drawn with bold edges and interface calls with dashed ones.
+func writeCallGraphDOT(w io.Writer, graph *CallGraph) error {
+	var sb strings.Builder
+	sb.WriteString("digraph callgraph {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(&sb, "\t%s [label=%s];\n", strconv.Quote(node.ID), strconv.Quote(node.Name))
+	}
+	for _, edge := range graph.Edges {
+		style := ""
+		switch edge.Kind {
+		case MethodCall:
+			style = " [style=bold]"
+		case InterfaceCall:
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s%s;\n", strconv.Quote(edge.Caller), strconv.Quote(edge.Callee), style)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
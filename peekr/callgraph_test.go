@@ -0,0 +1,107 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCallGraph(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/cg\n",
+		"shape/shape.go": `package shape
+
+type Shape interface{ Area() int }
+
+type Square struct{ Side int }
+
+func (s *Square) Area() int { return square(s.Side) }
+
+func square(n int) int { return n * n }
+
+func Total(shapes []Shape) int {
+	sum := 0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
+`,
+		"app/app.go": `package app
+
+import "example.com/cg/shape"
+
+func Run() int {
+	sq := &shape.Square{Side: 2}
+	f := func() int { return sq.Area() }
+	return shape.Total([]shape.Shape{sq}) + f()
+}
+`,
+	})
+
+	edges := func(graph *CallGraph) []string {
+		var edges []string
+		for _, edge := range graph.Edges {
+			edges = append(edges, edge.Caller+" -> "+edge.Callee+" ("+edge.Kind+")")
+		}
+		return edges
+	}
+
+	t.Run("Package", func(t *testing.T) {
+		graph, err := BuildCallGraph(context.Background(), Options{Dir: root, Package: "shape"}, CallGraphOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"(*example.com/cg/shape.Square).Area -> example.com/cg/shape.square (static)",
+			"example.com/cg/shape.Total -> (*example.com/cg/shape.Square).Area (interface)",
+		}, edges(graph))
+		require.Len(t, graph.Nodes, 3)
+		assert.Equal(t, "(*shape.Square).Area", graph.Nodes[0].Name)
+		assert.Equal(t, 7, graph.Nodes[0].Line)
+	})
+
+	t.Run("Module from root", func(t *testing.T) {
+		opts := CallGraphOptions{Module: true, Root: "app.Run", Depth: 1}
+		graph, err := BuildCallGraph(context.Background(), Options{Dir: root}, opts)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"example.com/cg/app.Run -> (*example.com/cg/shape.Square).Area (method)",
+			"example.com/cg/app.Run -> example.com/cg/shape.Total (static)",
+			"example.com/cg/shape.Total -> (*example.com/cg/shape.Square).Area (interface)",
+		}, edges(graph))
+		assert.Len(t, graph.Nodes, 3)
+
+		opts.Depth = 0
+		graph, err = BuildCallGraph(context.Background(), Options{Dir: root}, opts)
+		require.NoError(t, err)
+		assert.Len(t, graph.Edges, 4)
+	})
+
+	t.Run("Formats", func(t *testing.T) {
+		graph, err := BuildCallGraph(context.Background(), Options{Dir: root, Package: "shape"}, CallGraphOptions{})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteCallGraph(&buf, graph, "dot"))
+		assert.Contains(t, buf.String(), "digraph callgraph {")
+		assert.Contains(t, buf.String(), `"example.com/cg/shape.Total" -> "(*example.com/cg/shape.Square).Area" [style=dashed];`)
+
+		buf.Reset()
+		require.NoError(t, WriteCallGraph(&buf, graph, "json"))
+		var decoded CallGraph
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, graph.Edges, decoded.Edges)
+
+		assert.ErrorContains(t, WriteCallGraph(&buf, graph, "svg"), `unknown format "svg"`)
+	})
+
+	t.Run("Missing package", func(t *testing.T) {
+		_, err := BuildCallGraph(context.Background(), Options{Dir: root, Package: "shapes"}, CallGraphOptions{})
+		var notFound *PackageNotFoundError
+		assert.ErrorAs(t, err, &notFound)
+	})
+}
@@ -0,0 +1,206 @@
+package peekr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// DependentsOptions controls where FindDependents looks for dependents.
+type DependentsOptions struct {
+	// Consumers are directories of other modules on disk, such as sibling
+	// services, whose packages are searched as well.
+	Consumers []string
+}
+
+// DependentSymbol is a symbol of the package that a dependent uses.
+type DependentSymbol struct {
+	Kind string `json:"kind"` // "func", "method", "type", "field", "const" or "var"
+	Name string `json:"name"` // e.g. "helpers.TerminalColor" or "helpers.Terminal.Width"
+	Uses int    `json:"uses"` // Number of references from the dependent
+}
+
+// Dependent is a package that imports the package FindDependents was asked
+// about.
+type Dependent struct {
+	Package  string            `json:"package"`            // Import path of the dependent
+	Dir      string            `json:"dir"`                // Directory of the dependent
+	Consumer bool              `json:"consumer,omitempty"` // Found in a consumer module rather than the workspace
+	Symbols  []DependentSymbol `json:"symbols"`            // Symbols used, sorted by name; empty for blank imports
+}
+
+// Dependents is the result of FindDependents.
+type Dependents struct {
+	Package     string       `json:"package"`    // Import path of the package
+	Dependents  []Dependent  `json:"dependents"` // Sorted by import path
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// FindDependents type-checks every package of the workspace containing
+// opts.Dir, and of the consumer modules, and returns the packages that
+// import the package selected by opts.Package along with the symbols of it
+// that each one uses. Uses of a method or field are reported under the type
+// declaring it, as "pkg.Type.Member". External test packages are only
+// considered when opts.Tests is set. A package name shared by several
+// workspace packages is an error listing their import paths.
+func FindDependents(ctx context.Context, opts Options, depOpts DependentsOptions) (*Dependents, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("FindDependents(): Options.Dir is required")
+	}
+	if opts.Package == "" {
+		return nil, errors.New("FindDependents(): Options.Package is required")
+	}
+	prog, err := loadProgram(ctx, opts, depOpts.Consumers...)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*typedPackage
+	for _, tp := range prog.selectPackages(opts.Package) {
+		if !strings.HasSuffix(tp.path, "_test") {
+			candidates = append(candidates, tp)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, newPackageNotFoundError(opts.Dir, opts.Package, opts)
+	}
+	if len(candidates) > 1 {
+		paths := make([]string, len(candidates))
+		for i, tp := range candidates {
+			paths[i] = tp.path
+		}
+		return nil, fmt.Errorf("FindDependents(): package %q is ambiguous, use the import path: %s", opts.Package, strings.Join(paths, ", "))
+	}
+	target := candidates[0]
+	_, owners := prog.declarations()
+
+	result := &Dependents{Package: target.path, Diagnostics: prog.diagnostics}
+	for _, tp := range prog.packages {
+		if tp == target || !importsPackage(tp.types, target.types) {
+			continue
+		}
+		dependent := Dependent{Package: tp.path, Dir: tp.dir, Consumer: !prog.inWorkspace(tp.types)}
+		uses := make(map[string]*DependentSymbol)
+		for _, obj := range tp.info.Uses {
+			obj = originObject(obj)
+			if obj.Pkg() != target.types {
+				continue
+			}
+			symbol := dependentSymbol(obj, owners)
+			if symbol.Name == "" {
+				continue
+			}
+			if seen, ok := uses[symbol.Name]; ok {
+				seen.Uses++
+				continue
+			}
+			symbol.Uses = 1
+			uses[symbol.Name] = &symbol
+		}
+		for _, symbol := range uses {
+			dependent.Symbols = append(dependent.Symbols, *symbol)
+		}
+		sort.Slice(dependent.Symbols, func(i, j int) bool { return dependent.Symbols[i].Name < dependent.Symbols[j].Name })
+		result.Dependents = append(result.Dependents, dependent)
+	}
+	sort.Slice(result.Dependents, func(i, j int) bool { return result.Dependents[i].Package < result.Dependents[j].Package })
+	return result, nil
+}
+
+// importsPackage reports whether pkg imports target directly.
+func importsPackage(pkg, target *types.Package) bool {
+	for _, imp := range pkg.Imports() {
+		if imp == target {
+			return true
+		}
+	}
+	return false
+}
+
+// dependentSymbol describes a use of obj, naming members after the type
+// that declares them. It returns an empty name for objects that are not
+// symbols of the package, such as local variables and parameters.
+func dependentSymbol(obj types.Object, owners map[types.Object]types.Object) DependentSymbol {
+	pkgName := obj.Pkg().Name()
+	switch obj := obj.(type) {
+	case *types.Func:
+		recv := obj.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return DependentSymbol{Kind: "func", Name: pkgName + "." + obj.Name()}
+		}
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			return DependentSymbol{Kind: "method", Name: pkgName + "." + named.Obj().Name() + "." + obj.Name()}
+		}
+		if owner, ok := owners[obj]; ok {
+			return DependentSymbol{Kind: "method", Name: pkgName + "." + owner.Name() + "." + obj.Name()}
+		}
+	case *types.Var:
+		if !obj.IsField() {
+			if obj.Parent() == obj.Pkg().Scope() {
+				return DependentSymbol{Kind: "var", Name: pkgName + "." + obj.Name()}
+			}
+			return DependentSymbol{}
+		}
+		if owner, ok := owners[obj]; ok {
+			return DependentSymbol{Kind: "field", Name: pkgName + "." + owner.Name() + "." + obj.Name()}
+		}
+	case *types.Const:
+		return DependentSymbol{Kind: "const", Name: pkgName + "." + obj.Name()}
+	case *types.TypeName:
+		if obj.Parent() == obj.Pkg().Scope() {
+			return DependentSymbol{Kind: "type", Name: pkgName + "." + obj.Name()}
+		}
+	}
+	return DependentSymbol{}
+}
+
+// PrintDependents prints the result of FindDependents.
+func PrintDependents(result *Dependents) {
+	writeDependents(os.Stdout, result)
+}
+
+// writeDependents writes every dependent of result to w, followed by the
+// symbols it uses and how often.
+func writeDependents(w io.Writer, result *Dependents) {
+	if len(result.Dependents) == 0 {
+		helpers.FprintColor(w, fmt.Sprintf("\nNo packages import '%s'.", result.Package), helpers.Debug)
+		return
+	}
+	header := fmt.Sprintf("\n%d package(s) import '%s':", len(result.Dependents), result.Package)
+	helpers.FprintColor(w, header, helpers.Info)
+
+	for _, dependent := range result.Dependents {
+		title := "\n" + dependent.Package
+		if dependent.Consumer {
+			title += "  [consumer]"
+		}
+		helpers.FprintColor(w, title, helpers.Cyan)
+		if len(dependent.Symbols) == 0 {
+			helpers.FprintColor(w, "  (no symbols used)", helpers.Warn)
+			continue
+		}
+		width := 0
+		for _, symbol := range dependent.Symbols {
+			if n := len(symbol.Kind) + 1 + len(symbol.Name); n > width {
+				width = n
+			}
+		}
+		for _, symbol := range dependent.Symbols {
+			noun := "uses"
+			if symbol.Uses == 1 {
+				noun = "use"
+			}
+			helpers.FprintColor(w, fmt.Sprintf("  %-*s  %d %s", width, symbol.Kind+" "+symbol.Name, symbol.Uses, noun), helpers.Debug)
+		}
+	}
+}
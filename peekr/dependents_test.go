@@ -0,0 +1,82 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDependents(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"lib/go.mod": "module example.com/lib\n",
+		"lib/util/util.go": `package util
+
+const Version = "1"
+
+type Point struct{ X, Y int }
+
+func (p *Point) Move() { p.X++ }
+
+func NewPoint() *Point { return &Point{} }
+`,
+		"lib/app/app.go": `package app
+
+import "example.com/lib/util"
+
+func Run() int {
+	p := util.NewPoint()
+	p.Move()
+	p.Move()
+	return p.X + len(util.Version)
+}
+`,
+		"lib/blank/blank.go": "package blank\n\nimport _ \"example.com/lib/util\"\n",
+		"lib/other/other.go": "package other\n",
+		"svc/go.mod":         "module example.com/svc\n\nrequire example.com/lib v0.0.0\n",
+		"svc/main.go":        "package main\n\nimport \"example.com/lib/util\"\n\nvar _ util.Point\n\nfunc main() {}\n",
+	})
+
+	result, err := FindDependents(context.Background(), Options{Dir: filepath.Join(root, "lib"), Package: "util"}, DependentsOptions{
+		Consumers: []string{filepath.Join(root, "svc")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/lib/util", result.Package)
+	require.Len(t, result.Dependents, 3)
+
+	assert.Equal(t, Dependent{
+		Package: "example.com/lib/app",
+		Dir:     filepath.Join(root, "lib", "app"),
+		Symbols: []DependentSymbol{
+			{Kind: "func", Name: "util.NewPoint", Uses: 1},
+			{Kind: "method", Name: "util.Point.Move", Uses: 2},
+			{Kind: "field", Name: "util.Point.X", Uses: 1},
+			{Kind: "const", Name: "util.Version", Uses: 1},
+		},
+	}, result.Dependents[0])
+	assert.Equal(t, "example.com/lib/blank", result.Dependents[1].Package)
+	assert.Empty(t, result.Dependents[1].Symbols)
+	assert.True(t, result.Dependents[2].Consumer)
+	assert.Equal(t, []DependentSymbol{{Kind: "type", Name: "util.Point", Uses: 1}}, result.Dependents[2].Symbols)
+
+	var buf bytes.Buffer
+	writeDependents(&buf, result)
+	assert.Contains(t, buf.String(), "3 package(s) import 'example.com/lib/util'")
+	assert.Contains(t, buf.String(), "[consumer]")
+
+	_, err = FindDependents(context.Background(), Options{Dir: filepath.Join(root, "lib"), Package: "missing"}, DependentsOptions{})
+	var notFound *PackageNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	// A second util package makes the name ambiguous.
+	writeTree(t, root, map[string]string{"lib/internal/util/util.go": "package util\n"})
+	_, err = FindDependents(context.Background(), Options{Dir: filepath.Join(root, "lib"), Package: "util"}, DependentsOptions{})
+	assert.ErrorContains(t, err, "example.com/lib/internal/util, example.com/lib/util")
+	result, err = FindDependents(context.Background(), Options{Dir: filepath.Join(root, "lib"), Package: "example.com/lib/util"}, DependentsOptions{})
+	require.NoError(t, err)
+	assert.Len(t, result.Dependents, 2)
+}
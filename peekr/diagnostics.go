@@ -0,0 +1,119 @@
+package peekr
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// Diagnostic describes a problem found while reading a package, such as a
+// syntax error or an unreadable directory. Diagnostics never stop a scan;
+// the affected file contributes whatever could be parsed.
+type Diagnostic struct {
+	File    string `json:"file"`             // Path of the file or directory with the problem
+	Line    int    `json:"line,omitempty"`   // 1-based line number, 0 when unknown
+	Column  int    `json:"column,omitempty"` // 1-based column number, 0 when unknown
+	Message string `json:"message"`          // Description of the problem
+}
+
+// String formats the diagnostic the way the go tool does: file:line:column: message.
+func (d Diagnostic) String() string {
+	switch {
+	case d.Line > 0 && d.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+	case d.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+	default:
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+}
+
+// diagnosticsFromError converts an error about path into diagnostics,
+// expanding go/scanner error lists into one diagnostic per position.
+func diagnosticsFromError(path string, err error) []Diagnostic {
+	var list scanner.ErrorList
+	if errors.As(err, &list) {
+		diags := make([]Diagnostic, 0, len(list))
+		for _, e := range list {
+			diags = append(diags, Diagnostic{
+				File:    e.Pos.Filename,
+				Line:    e.Pos.Line,
+				Column:  e.Pos.Column,
+				Message: e.Msg,
+			})
+		}
+		return diags
+	}
+	return []Diagnostic{{File: path, Message: err.Error()}}
+}
+
+// parseFile parses a Go source file with comments, reading it from disk
+// unless src holds its content. When the file has syntax errors the partial
+// syntax tree is still returned alongside diagnostics, so declarations
+// before and after the error are not lost. A nil file means the file could
+// not be read at all.
+func parseFile(fset *token.FileSet, path string, src any) (*ast.File, []Diagnostic) {
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return f, diagnosticsFromError(path, err)
+	}
+	return f, nil
+}
+
+// sortDiagnostics orders diagnostics by file and position and removes duplicates.
+func sortDiagnostics(diags []Diagnostic) []Diagnostic {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	unique := diags[:0]
+	for i, d := range diags {
+		if i > 0 && d == diags[i-1] {
+			continue
+		}
+		unique = append(unique, d)
+	}
+	return unique
+}
+
+// PrintDiagnostics prints a color-coded list of diagnostics, one per line in
+// file:line:column form. Duplicates reported by several scans are printed once.
+func PrintDiagnostics(diags []Diagnostic) {
+	writeDiagnostics(os.Stdout, diags)
+}
+
+// FprintDiagnostics is PrintDiagnostics for any writer, such as stderr when
+// stdout carries machine-readable output.
+func FprintDiagnostics(w io.Writer, diags []Diagnostic) {
+	writeDiagnostics(w, diags)
+}
+
+// writeDiagnostics writes the color-coded diagnostics report to w.
+func writeDiagnostics(w io.Writer, diags []Diagnostic) {
+	if len(diags) == 0 {
+		return
+	}
+	diags = sortDiagnostics(append([]Diagnostic(nil), diags...))
+
+	header := fmt.Sprintf("\n%d problem(s) found while reading the package; results may be incomplete:\n", len(diags))
+	helpers.FprintColor(w, header, helpers.Warn)
+	for _, d := range diags {
+		helpers.FprintColor(w, "  "+d.String(), helpers.Warn)
+	}
+	fmt.Fprintln(w)
+}
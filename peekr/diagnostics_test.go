@@ -0,0 +1,72 @@
+package peekr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageFunctionsDiagnostics(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":         "module example.com/broken\n",
+		"pkg/good.go":    "package pkg\n\nfunc Good() {}\n",
+		"pkg/broken.go":  "package pkg\n\nfunc BeforeError() {}\n\nfunc Broken( {\n",
+		"pkg/struct.go":  "package pkg\n\ntype Fine struct{ A int }\n",
+		"pkg/garbage.go": "this is not go\n",
+	})
+
+	funcMap, diags, err := PackageFunctions(root, "pkg", Options{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, functions := range funcMap {
+		for _, fn := range functions {
+			names = append(names, fn.Function)
+		}
+	}
+	assert.Contains(t, names, "Good")
+	assert.Contains(t, names, "BeforeError")
+
+	require.NotEmpty(t, diags)
+	files := make(map[string]bool)
+	for _, d := range diags {
+		files[filepath.Base(d.File)] = true
+		assert.Greater(t, d.Line, 0)
+		assert.NotEmpty(t, d.Message)
+	}
+	assert.Equal(t, map[string]bool{"broken.go": true, "garbage.go": true}, files)
+
+	structsMap, structDiags, err := PackageStructs(root, "pkg", Options{})
+	require.NoError(t, err)
+	assert.Len(t, structsMap, 1)
+	assert.Equal(t, diags, structDiags)
+}
+
+func TestDiagnosticsOfOtherPackages(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":       "module example.com/siblings\n",
+		"a/a.go":       "package a\n\nfunc A() {}\n",
+		"b/b.go":       "package b\n\nfunc Broken( {\n",
+		"c/garbage.go": "this is not go\n",
+	})
+
+	pkg, err := LoadPackage(root, "a", Options{})
+	require.NoError(t, err)
+	require.Len(t, pkg.Symbols, 1)
+
+	files := make(map[string]bool)
+	for _, d := range pkg.Diagnostics {
+		files[filepath.Base(d.File)] = true
+	}
+	assert.Equal(t, map[string]bool{"garbage.go": true}, files, "only files that may declare the package are reported")
+}
+
+func TestDiagnosticString(t *testing.T) {
+	assert.Equal(t, "a.go:3:5: boom", Diagnostic{File: "a.go", Line: 3, Column: 5, Message: "boom"}.String())
+	assert.Equal(t, "a.go:3: boom", Diagnostic{File: "a.go", Line: 3, Message: "boom"}.String())
+	assert.Equal(t, "dir: boom", Diagnostic{File: "dir", Message: "boom"}.String())
+}
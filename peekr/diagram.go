@@ -0,0 +1,397 @@
+package peekr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Define constants for the kinds of diagram classes and relations.
+const (
+	StructClass    = "struct"
+	InterfaceClass = "interface"
+
+	InheritanceRelation = "inheritance" // A struct embedding a type, or an interface embedding another
+	AssociationRelation = "association" // A struct with a field whose type refers to another class
+	RealizationRelation = "realization" // A struct implementing an interface
+)
+
+// DiagramFormats lists the formats WriteDiagram supports.
+var DiagramFormats = []string{"mermaid", "plantuml", "dot", "json"}
+
+// DiagramMember is a field or method of a diagram class.
+type DiagramMember struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // Field type, or "(params) results" for methods
+}
+
+// DiagramClass is a struct or interface of the package.
+type DiagramClass struct {
+	Name    string          `json:"name"`
+	Kind    string          `json:"kind"` // StructClass or InterfaceClass
+	Fields  []DiagramMember `json:"fields,omitempty"`
+	Methods []DiagramMember `json:"methods,omitempty"`
+	File    string          `json:"file"`
+	Line    int             `json:"line"`
+}
+
+// DiagramRelation is a relationship between two classes of a diagram.
+type DiagramRelation struct {
+	From  string `json:"from"`            // The embedding struct, the struct with the field, or the implementation
+	To    string `json:"to"`              // The embedded type, the field type, or the interface
+	Kind  string `json:"kind"`            // InheritanceRelation, AssociationRelation or RealizationRelation
+	Label string `json:"label,omitempty"` // Field names of an association
+}
+
+// Diagram is a UML class diagram of a package. Classes are in source order
+// and relations are sorted by source, target and kind.
+type Diagram struct {
+	Package     string            `json:"package"`
+	Classes     []DiagramClass    `json:"classes"`
+	Relations   []DiagramRelation `json:"relations"`
+	Diagnostics []Diagnostic      `json:"diagnostics,omitempty"`
+}
+
+// BuildDiagram returns a class diagram of the exported structs and
+// interfaces of the package selected by opts.Dir and opts.Package. Structs
+// and their fields and methods come from the symbols peekr extracts; the
+// same files are then type-checked to find the interfaces and the relations
+// between classes. Only relations between classes of the package are drawn.
+// A package name shared by several directories is an error, since their
+// classes could not be told apart; the import path selects one of them.
+func BuildDiagram(ctx context.Context, opts Options) (*Diagram, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("BuildDiagram(): Options.Dir is required")
+	}
+	if opts.Package == "" {
+		return nil, errors.New("BuildDiagram(): Options.Package is required")
+	}
+	opts = opts.typeCheckTarget()
+	target, err := resolveTarget(ctx, opts.Dir, opts.Package, opts)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	parsed, err := parseFiles(ctx, fset, target.files, opts, target.clauseFilter())
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := buildPackage(fset, opts.Dir, opts.Package, opts, target, parsed)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := LoadWorkspace(opts.Dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	checked, err := newTypeLoader(ctx, fset, ws, opts).checkParsed(target.packageFiles(parsed))
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*typedPackage
+	for _, tp := range checked {
+		if !strings.HasSuffix(tp.path, "_test") {
+			candidates = append(candidates, tp)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, newPackageNotFoundError(opts.Dir, opts.Package, opts)
+	}
+	if len(candidates) > 1 {
+		paths := make([]string, len(candidates))
+		for i, tp := range candidates {
+			paths[i] = tp.path
+		}
+		return nil, fmt.Errorf("BuildDiagram(): package %q is ambiguous, use the import path: %s", opts.Package, strings.Join(paths, ", "))
+	}
+	tp := candidates[0]
+
+	diagram := &Diagram{Package: tp.types.Name(), Diagnostics: pkg.Diagnostics}
+	classes := make(map[string]int)
+	for _, symbol := range pkg.Symbols {
+		if symbol.Kind != StructSymbol || symbol.TestKind != "" || isTestFile(symbol.File) {
+			continue
+		}
+		class := DiagramClass{Name: symbol.Name, Kind: StructClass, File: symbol.File, Line: symbol.Line}
+		for _, field := range symbol.Children {
+			class.Fields = append(class.Fields, DiagramMember{Name: field.Name, Type: field.Signature})
+		}
+		classes[class.Name] = len(diagram.Classes)
+		diagram.Classes = append(diagram.Classes, class)
+	}
+	for _, symbol := range pkg.Symbols {
+		if symbol.Kind != FuncSymbol || symbol.Receiver == "" || isTestFile(symbol.File) {
+			continue
+		}
+		if i, ok := classes[receiverTypeName(symbol.Receiver)]; ok {
+			diagram.Classes[i].Methods = append(diagram.Classes[i].Methods, DiagramMember{Name: symbol.Name, Type: symbol.Signature})
+		}
+	}
+
+	qualifier := types.RelativeTo(tp.types)
+	var ifaces []*types.TypeName
+	for _, obj := range namedTypes(tp.types, true) {
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		ifaces = append(ifaces, obj)
+		pos := fset.Position(obj.Pos())
+		class := DiagramClass{Name: obj.Name(), Kind: InterfaceClass, File: pos.Filename, Line: pos.Line}
+		for i := 0; i < iface.NumExplicitMethods(); i++ {
+			method := iface.ExplicitMethod(i)
+			signature := types.TypeString(method.Type(), qualifier)
+			class.Methods = append(class.Methods, DiagramMember{Name: method.Name(), Type: strings.TrimPrefix(signature, "func")})
+		}
+		classes[class.Name] = len(diagram.Classes)
+		diagram.Classes = append(diagram.Classes, class)
+	}
+	sort.SliceStable(diagram.Classes, func(i, j int) bool {
+		a, b := diagram.Classes[i], diagram.Classes[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	// Relations are collected per source, target and kind; the labels of an
+	// association list every field that creates it.
+	relations := make(map[[3]string][]string)
+	relate := func(from string, to types.Type, kind, label string) {
+		named := namedIn(to, tp.types)
+		if named == nil {
+			return
+		}
+		if _, ok := classes[named.Obj().Name()]; !ok {
+			return
+		}
+		key := [3]string{from, named.Obj().Name(), kind}
+		if label != "" {
+			relations[key] = append(relations[key], label)
+		} else if _, ok := relations[key]; !ok {
+			relations[key] = nil
+		}
+	}
+
+	for _, class := range diagram.Classes {
+		obj, ok := tp.types.Scope().Lookup(class.Name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		switch underlying := obj.Type().Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < underlying.NumFields(); i++ {
+				field := underlying.Field(i)
+				if field.Embedded() {
+					relate(class.Name, field.Type(), InheritanceRelation, "")
+					continue
+				}
+				for _, t := range referencedTypes(field.Type()) {
+					relate(class.Name, t, AssociationRelation, field.Name())
+				}
+			}
+			for _, iface := range ifaces {
+				methods := iface.Type().Underlying().(*types.Interface)
+				if methods.NumMethods() == 0 {
+					continue
+				}
+				if _, ok := satisfies(obj.Type(), methods); ok {
+					relate(class.Name, iface.Type(), RealizationRelation, "")
+				}
+			}
+		case *types.Interface:
+			for i := 0; i < underlying.NumEmbeddeds(); i++ {
+				relate(class.Name, underlying.EmbeddedType(i), InheritanceRelation, "")
+			}
+		}
+	}
+
+	for key, labels := range relations {
+		diagram.Relations = append(diagram.Relations, DiagramRelation{From: key[0], To: key[1], Kind: key[2], Label: strings.Join(labels, ", ")})
+	}
+	sort.Slice(diagram.Relations, func(i, j int) bool {
+		a, b := diagram.Relations[i], diagram.Relations[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+	return diagram, nil
+}
+
+// receiverTypeName returns the name of the type of a method receiver, e.g.
+// "Options" for "*Options" or "List" for "*List[T]".
+func receiverTypeName(receiver string) string {
+	name := strings.TrimPrefix(receiver, "*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// namedIn returns the named type declared in pkg that t is, or points to.
+func namedIn(t types.Type, pkg *types.Package) *types.Named {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() != pkg {
+		return nil
+	}
+	return named
+}
+
+// referencedTypes returns the named types a field type refers to through
+// pointers, slices, arrays, maps and channels, e.g. Symbol and Diagnostic
+// for map[Symbol][]*Diagnostic.
+func referencedTypes(t types.Type) []types.Type {
+	switch t := t.(type) {
+	case *types.Named:
+		return []types.Type{t}
+	case *types.Pointer:
+		return referencedTypes(t.Elem())
+	case *types.Slice:
+		return referencedTypes(t.Elem())
+	case *types.Array:
+		return referencedTypes(t.Elem())
+	case *types.Chan:
+		return referencedTypes(t.Elem())
+	case *types.Map:
+		return append(referencedTypes(t.Key()), referencedTypes(t.Elem())...)
+	}
+	return nil
+}
+
+// WriteDiagram writes diagram to w in one of the DiagramFormats: "mermaid",
+// "plantuml", "dot" for Graphviz, or "json" for the Diagram itself.
+func WriteDiagram(w io.Writer, diagram *Diagram, format string) error {
+	var sb strings.Builder
+	switch format {
+	case "mermaid":
+		writeDiagramMermaid(&sb, diagram)
+	case "plantuml":
+		writeDiagramPlantUML(&sb, diagram)
+	case "dot":
+		writeDiagramDOT(&sb, diagram)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diagram)
+	default:
+		return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(DiagramFormats, ", "))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// visibility returns the UML visibility marker of a Go identifier.
+func visibility(name string) string {
+	if token.IsExported(name) {
+		return "+"
+	}
+	return "-"
+}
+
+// writeDiagramMermaid writes diagram as a Mermaid class diagram.
+func writeDiagramMermaid(sb *strings.Builder, diagram *Diagram) {
+	// Mermaid reads brackets in member types as generics markers.
+	escape := strings.NewReplacer("{", "#123;", "}", "#125;").Replace
+	sb.WriteString("classDiagram\n")
+	for _, class := range diagram.Classes {
+		fmt.Fprintf(sb, "    class %s {\n", class.Name)
+		if class.Kind == InterfaceClass {
+			sb.WriteString("        <<interface>>\n")
+		}
+		for _, field := range class.Fields {
+			fmt.Fprintf(sb, "        %s%s %s\n", visibility(field.Name), field.Name, escape(field.Type))
+		}
+		for _, method := range class.Methods {
+			fmt.Fprintf(sb, "        %s%s%s\n", visibility(method.Name), method.Name, escape(method.Type))
+		}
+		sb.WriteString("    }\n")
+	}
+	for _, relation := range diagram.Relations {
+		switch relation.Kind {
+		case InheritanceRelation:
+			fmt.Fprintf(sb, "    %s <|-- %s\n", relation.To, relation.From)
+		case RealizationRelation:
+			fmt.Fprintf(sb, "    %s <|.. %s\n", relation.To, relation.From)
+		case AssociationRelation:
+			fmt.Fprintf(sb, "    %s --> %s : %s\n", relation.From, relation.To, relation.Label)
+		}
+	}
+}
+
+// writeDiagramPlantUML writes diagram as a PlantUML class diagram.
+func writeDiagramPlantUML(sb *strings.Builder, diagram *Diagram) {
+	sb.WriteString("@startuml\n")
+	fmt.Fprintf(sb, "title %s\n", diagram.Package)
+	for _, class := range diagram.Classes {
+		keyword := "class"
+		if class.Kind == InterfaceClass {
+			keyword = "interface"
+		}
+		fmt.Fprintf(sb, "%s %s {\n", keyword, class.Name)
+		for _, field := range class.Fields {
+			fmt.Fprintf(sb, "  {field} %s%s : %s\n", visibility(field.Name), field.Name, field.Type)
+		}
+		for _, method := range class.Methods {
+			fmt.Fprintf(sb, "  {method} %s%s%s\n", visibility(method.Name), method.Name, method.Type)
+		}
+		sb.WriteString("}\n")
+	}
+	for _, relation := range diagram.Relations {
+		switch relation.Kind {
+		case InheritanceRelation:
+			fmt.Fprintf(sb, "%s <|-- %s\n", relation.To, relation.From)
+		case RealizationRelation:
+			fmt.Fprintf(sb, "%s <|.. %s\n", relation.To, relation.From)
+		case AssociationRelation:
+			fmt.Fprintf(sb, "%s --> %s : %s\n", relation.From, relation.To, relation.Label)
+		}
+	}
+	sb.WriteString("@enduml\n")
+}
+
+// writeDiagramDOT writes diagram as a Graphviz digraph of record nodes.
+// Inheritance is drawn with hollow arrowheads, realization with dashed
+// edges and hollow arrowheads, and associations with open arrowheads.
+func writeDiagramDOT(sb *strings.Builder, diagram *Diagram) {
+	escape := strings.NewReplacer(`\`, `\\`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`, `"`, `\"`).Replace
+	sb.WriteString("digraph diagram {\n\trankdir=BT;\n\tnode [shape=record];\n")
+	for _, class := range diagram.Classes {
+		title := class.Name
+		if class.Kind == InterfaceClass {
+			title = `\<\<interface\>\>\n` + title
+		}
+		var fields, methods strings.Builder
+		for _, field := range class.Fields {
+			fields.WriteString(escape(visibility(field.Name)+field.Name+" "+field.Type) + `\l`)
+		}
+		for _, method := range class.Methods {
+			methods.WriteString(escape(visibility(method.Name)+method.Name+method.Type) + `\l`)
+		}
+		fmt.Fprintf(sb, "\t%s [label=\"{%s|%s|%s}\"];\n", strconv.Quote(class.Name), title, fields.String(), methods.String())
+	}
+	for _, relation := range diagram.Relations {
+		attrs := "arrowhead=empty"
+		switch relation.Kind {
+		case RealizationRelation:
+			attrs = "arrowhead=empty, style=dashed"
+		case AssociationRelation:
+			attrs = "arrowhead=vee, label=" + strconv.Quote(relation.Label)
+		}
+		fmt.Fprintf(sb, "\t%s -> %s [%s];\n", strconv.Quote(relation.From), strconv.Quote(relation.To), attrs)
+	}
+	sb.WriteString("}\n")
+}
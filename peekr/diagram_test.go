@@ -0,0 +1,105 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDiagram(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/uml\n",
+		"shapes/shapes.go": `package shapes
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Named shapes have a name.
+type Named interface {
+	Shape
+	Name() string
+}
+
+// Base holds what every shape shares.
+type Base struct {
+	Label string
+}
+
+// Name returns the label.
+func (b Base) Name() string { return b.Label }
+
+// Square is a named shape.
+type Square struct {
+	Base
+	Side   float64
+	Parent *Square
+	Others []Shape
+}
+
+// Area returns the area of the square.
+func (s *Square) Area() float64 { return s.Side * s.Side }
+`,
+	})
+
+	diagram, err := BuildDiagram(context.Background(), Options{Dir: root, Package: "shapes"})
+	require.NoError(t, err)
+	assert.Equal(t, "shapes", diagram.Package)
+
+	var classes []string
+	for _, class := range diagram.Classes {
+		classes = append(classes, class.Kind+" "+class.Name)
+	}
+	assert.Equal(t, []string{"interface Shape", "interface Named", "struct Base", "struct Square"}, classes)
+	assert.Equal(t, []DiagramMember{{Name: "Name", Type: "() string"}}, diagram.Classes[1].Methods, "embedded methods are not repeated")
+	assert.Equal(t, []DiagramMember{{Name: "Area", Type: "() float64"}}, diagram.Classes[3].Methods)
+	assert.Equal(t, []DiagramRelation{
+		{From: "Named", To: "Shape", Kind: InheritanceRelation},
+		{From: "Square", To: "Base", Kind: InheritanceRelation},
+		{From: "Square", To: "Named", Kind: RealizationRelation},
+		{From: "Square", To: "Shape", Kind: AssociationRelation, Label: "Others"},
+		{From: "Square", To: "Shape", Kind: RealizationRelation},
+		{From: "Square", To: "Square", Kind: AssociationRelation, Label: "Parent"},
+	}, diagram.Relations)
+
+	t.Run("Formats", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteDiagram(&buf, diagram, "mermaid"))
+		assert.Contains(t, buf.String(), "class Named {\n        <<interface>>\n        +Name() string\n    }\n")
+		assert.Contains(t, buf.String(), "    Shape <|.. Square\n")
+		assert.Contains(t, buf.String(), "    Square --> Shape : Others\n")
+
+		buf.Reset()
+		require.NoError(t, WriteDiagram(&buf, diagram, "plantuml"))
+		assert.Contains(t, buf.String(), "interface Shape {\n  {method} +Area() float64\n}\n")
+		assert.Contains(t, buf.String(), "Base <|-- Square\n")
+
+		buf.Reset()
+		require.NoError(t, WriteDiagram(&buf, diagram, "dot"))
+		assert.Contains(t, buf.String(), `"Square" [label="{Square|+Side float64\l+Parent *Square\l+Others []Shape\l|+Area() float64\l}"];`)
+		assert.Contains(t, buf.String(), `"Square" -> "Named" [arrowhead=empty, style=dashed];`)
+
+		assert.Error(t, WriteDiagram(&buf, diagram, "svg"))
+	})
+
+	t.Run("Ambiguous", func(t *testing.T) {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{
+			"go.mod":           "module example.com/twice\n",
+			"a/model/model.go": "package model\n\ntype Item struct{ A int }\n",
+			"b/model/model.go": "package model\n\ntype Item struct{ B string }\n",
+		})
+		_, err := BuildDiagram(context.Background(), Options{Dir: root, Package: "model"})
+		assert.ErrorContains(t, err, "example.com/twice/a/model, example.com/twice/b/model")
+
+		diagram, err := BuildDiagram(context.Background(), Options{Dir: root, Package: "example.com/twice/b/model"})
+		require.NoError(t, err)
+		require.Len(t, diagram.Classes, 1)
+		assert.Equal(t, []DiagramMember{{Name: "B", Type: "string"}}, diagram.Classes[0].Fields)
+	})
+}
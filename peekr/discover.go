@@ -0,0 +1,338 @@
+package peekr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// scanTarget describes the files to parse for a package and which package
+// clause a parsed file must have to be included.
+type scanTarget struct {
+	files     []string
+	pkgName   string          // Package clause to match; empty accepts any package
+	tests     bool            // Also accept the external pkgName_test package
+	generated GeneratedFilter // Whether generated files are accepted
+
+	diagnostics []Diagnostic // Problems found while discovering files
+}
+
+// inPackage reports whether a parsed file declares the target package.
+func (t scanTarget) inPackage(f *ast.File) bool {
+	switch {
+	case f.Name == nil || f.Name.Name == "":
+		return false
+	case t.pkgName == "" || f.Name.Name == t.pkgName:
+		return true
+	default:
+		return t.tests && f.Name.Name == t.pkgName+"_test"
+	}
+}
+
+// clauseFilter returns the filter parseFiles uses to skip the files of other
+// packages past their package clause, or nil when every file is wanted.
+func (t scanTarget) clauseFilter() func(*ast.File) bool {
+	if t.pkgName == "" {
+		return nil
+	}
+	return t.inPackage
+}
+
+// packageFiles returns the parsed files that declare the target package,
+// generated or not.
+func (t scanTarget) packageFiles(parsed []*parsedFile) []*parsedFile {
+	var files []*parsedFile
+	for _, pf := range parsed {
+		if pf.file != nil && t.inPackage(pf.file) {
+			files = append(files, pf)
+		}
+	}
+	return files
+}
+
+// isImportPath reports whether pkg looks like an import path rather than a
+// package name. Package names can never contain a slash or a dot.
+func isImportPath(pkg string) bool {
+	return strings.ContainsAny(pkg, "/.")
+}
+
+// resolveTarget determines which files make up the requested package.
+// A plain package name matches every directory in the workspace whose files
+// declare that name. An import path is resolved through the workspace modules
+// (and the vendor directory when Options.Vendor is set) to a single directory.
+// Files excluded by the build target in opts are dropped in both cases.
+// Every file found is reported to opts.Progress.
+func resolveTarget(ctx context.Context, dir, pkg string, opts Options) (scanTarget, error) {
+	ws, err := LoadWorkspace(dir, opts)
+	if err != nil {
+		return scanTarget{}, err
+	}
+
+	if isImportPath(pkg) {
+		pkgDir, ok := ws.resolveImportPath(pkg, opts)
+		if !ok {
+			return scanTarget{}, nil
+		}
+		files, err := goFilesIn(pkgDir, opts)
+		if err != nil {
+			return scanTarget{}, err
+		}
+		report := opts.progress()
+		for i, file := range files {
+			report(ProgressEvent{Kind: ProgressDiscovered, File: file, Count: i + 1})
+		}
+		files, err = filterBuildConstraints(files, opts)
+		if err != nil {
+			return scanTarget{}, err
+		}
+		return scanTarget{files: files, tests: opts.Tests, generated: opts.Generated}, nil
+	}
+
+	files, diags, err := ws.sourceFiles(ctx, opts)
+	if err != nil {
+		return scanTarget{}, err
+	}
+	files, err = filterBuildConstraints(files, opts)
+	if err != nil {
+		return scanTarget{}, err
+	}
+	return scanTarget{files: files, pkgName: pkg, tests: opts.Tests, generated: opts.Generated, diagnostics: diags}, nil
+}
+
+// resolveImportPath maps an import path to the directory holding its sources.
+func (ws *Workspace) resolveImportPath(importPath string, opts Options) (string, bool) {
+	if mod, ok := ws.ModuleFor(importPath); ok {
+		rel := strings.TrimPrefix(strings.TrimPrefix(importPath, mod.Path), "/")
+		pkgDir := filepath.Join(mod.Dir, filepath.FromSlash(rel))
+		if isDir(pkgDir) {
+			return pkgDir, true
+		}
+	}
+	if !opts.Vendor {
+		return "", false
+	}
+	for _, mod := range ws.Modules {
+		vendorDir := filepath.Join(mod.Dir, "vendor")
+		allowed, err := vendoredPackages(vendorDir)
+		if err != nil {
+			continue
+		}
+		if allowed != nil && !allowed[importPath] {
+			continue
+		}
+		pkgDir := filepath.Join(vendorDir, filepath.FromSlash(importPath))
+		if isDir(pkgDir) {
+			return pkgDir, true
+		}
+	}
+	return "", false
+}
+
+// sourceFiles walks every workspace root and returns the Go files found, in
+// lexical order and without duplicates. _test.go files are only returned when
+// Options.Tests is set. Ignored and excluded paths are never visited, and
+// vendor directories are skipped unless Options.Vendor is set. In workspace
+// mode, nested modules that go.work does not list are skipped, just as the
+// go command ignores them. Directories that cannot be read are reported as
+// diagnostics and skipped. Every file found is reported to opts.Progress, and
+// the walk stops with ctx.Err() once ctx is done.
+func (ws *Workspace) sourceFiles(ctx context.Context, opts Options) ([]string, []Diagnostic, error) {
+	seen := make(map[string]bool)
+	var files []string
+	var diags []Diagnostic
+	report := opts.progress()
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+			report(ProgressEvent{Kind: ProgressDiscovered, File: path, Count: len(files)})
+		}
+	}
+
+	for _, root := range ws.Roots() {
+		filter, err := newPathFilter(root, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				diags = append(diags, diagnosticsFromError(path, err)...)
+				return nil
+			}
+
+			if info.IsDir() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if path == root {
+					return nil
+				}
+				skip, err := filter.skipDir(path)
+				if err != nil {
+					return err
+				}
+				if skip {
+					return filepath.SkipDir
+				}
+				if info.Name() == "vendor" {
+					if opts.Vendor {
+						vendored, err := vendorFiles(path)
+						if err != nil {
+							return err
+						}
+						for _, file := range vendored {
+							skip, err := filter.skipFile(file)
+							if err != nil {
+								return err
+							}
+							if !skip {
+								add(file)
+							}
+						}
+					}
+					return filepath.SkipDir
+				}
+				if ws.GoWork != "" && !ws.isModuleRoot(path) && isFile(filepath.Join(path, "go.mod")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !isGoFile(info.Name(), opts.Tests) {
+				return nil
+			}
+			skip, err := filter.skipFile(path)
+			if err != nil {
+				return err
+			}
+			if !skip {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, diags, nil
+}
+
+// vendorFiles returns the Go files of every vendored package. When
+// vendor/modules.txt exists only the packages it lists are returned, which
+// mirrors how -mod=vendor decides what is part of the build.
+func vendorFiles(vendorDir string) ([]string, error) {
+	allowed, err := vendoredPackages(vendorDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.Walk(vendorDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isGoFile(info.Name(), false) {
+			return nil
+		}
+		if allowed != nil {
+			rel, err := filepath.Rel(vendorDir, filepath.Dir(path))
+			if err != nil || !allowed[filepath.ToSlash(rel)] {
+				return nil
+			}
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// vendoredPackages reads vendor/modules.txt and returns the set of vendored
+// import paths. A nil set with a nil error means no modules.txt was found and
+// every vendored directory should be accepted.
+func vendoredPackages(vendorDir string) (map[string]bool, error) {
+	f, err := os.Open(filepath.Join(vendorDir, "modules.txt"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vendoredPackages(): %w", err)
+	}
+	defer f.Close()
+
+	allowed := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allowed[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("vendoredPackages(): %w", err)
+	}
+	return allowed, nil
+}
+
+// goFilesIn returns the Go files directly inside dir, including _test.go
+// files when Options.Tests is set and skipping ignored or excluded files.
+func goFilesIn(dir string, opts Options) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("goFilesIn(): %w", err)
+	}
+	filter, err := newPathFilter(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isGoFile(entry.Name(), opts.Tests) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		skip, err := filter.skipFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// isGoFile reports whether name is a Go source file, accepting _test.go
+// files only when tests is set.
+func isGoFile(name string, tests bool) bool {
+	if !strings.HasSuffix(name, ".go") {
+		return false
+	}
+	return tests || !isTestFile(name)
+}
+
+// isTestFile reports whether name is a _test.go file.
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.go")
+}
+
+// isDir reports whether path exists and is a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isFile reports whether path exists and is a regular file.
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
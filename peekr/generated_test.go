@@ -0,0 +1,69 @@
+package peekr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedFilter(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":        "module example.com/gen\n",
+		"gen/manual.go": "package gen\n\n// Manual is written by hand.\ntype Manual struct{ A int }\n\nfunc Handwritten() {}\n",
+		"gen/gen.pb.go": "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage gen\n\ntype Message struct{ B int }\n\nfunc (m *Message) Reset() {}\n",
+	})
+
+	testCases := []struct {
+		name      string
+		filter    GeneratedFilter
+		functions map[string]bool
+		structs   map[string]bool
+	}{
+		{
+			name:      "Include",
+			filter:    IncludeGenerated,
+			functions: map[string]bool{"Handwritten": false, "Reset": true},
+			structs:   map[string]bool{"Manual": false, "Message": true},
+		},
+		{
+			name:      "Exclude",
+			filter:    ExcludeGenerated,
+			functions: map[string]bool{"Handwritten": false},
+			structs:   map[string]bool{"Manual": false},
+		},
+		{
+			name:      "Only",
+			filter:    OnlyGenerated,
+			functions: map[string]bool{"Reset": true},
+			structs:   map[string]bool{"Message": true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := Options{Generated: tc.filter}
+
+			funcMap, _, err := PackageFunctions(root, "gen", opts)
+			require.NoError(t, err)
+			functions := make(map[string]bool)
+			for _, fns := range funcMap {
+				for _, fn := range fns {
+					functions[fn.Function] = fn.Generated
+				}
+			}
+			assert.Equal(t, tc.functions, functions)
+
+			structsMap, _, err := PackageStructs(root, "gen", opts)
+			require.NoError(t, err)
+			structs := make(map[string]bool)
+			for _, sis := range structsMap {
+				for _, si := range sis {
+					structs[si.Name] = si.Generated
+				}
+			}
+			assert.Equal(t, tc.structs, structs)
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package peekr
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName is the name of peekr's own ignore file. It uses gitignore
+// syntax and may appear in any directory of the scanned tree.
+const IgnoreFileName = ".peekrignore"
+
+// defaultIgnorePatterns are directories the go command never treats as part
+// of a package tree, plus node_modules, which is never Go code worth scanning.
+var defaultIgnorePatterns = []string{
+	".*/",
+	"_*/",
+	"testdata/",
+	"node_modules/",
+}
+
+// ignoreRule is a single compiled gitignore-style pattern.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool // Pattern started with "!" and re-includes matching paths
+	dirOnly bool // Pattern ended with "/" and only matches directories
+}
+
+// ignoreList is an ordered set of rules whose patterns are relative to base.
+type ignoreList struct {
+	base  string
+	rules []ignoreRule
+}
+
+// newIgnoreList compiles gitignore-style patterns relative to base.
+func newIgnoreList(base string, patterns []string) (*ignoreList, error) {
+	list := &ignoreList{base: base}
+	for _, pattern := range patterns {
+		rule, ok, err := compileIgnorePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			list.rules = append(list.rules, rule)
+		}
+	}
+	return list, nil
+}
+
+// loadIgnoreFile reads an ignore file from dir. It returns nil when the file
+// does not exist.
+func loadIgnoreFile(dir, name string) (*ignoreList, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loadIgnoreFile(): %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("loadIgnoreFile(): %w", err)
+	}
+	return newIgnoreList(dir, patterns)
+}
+
+// match evaluates the rules against path. The first result reports whether
+// any rule matched; the second whether the last matching rule ignores path.
+func (l *ignoreList) match(path string, isDir bool) (bool, bool) {
+	rel, err := filepath.Rel(l.base, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	matched, ignored := false, false
+	for _, rule := range l.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(rel) {
+			matched = true
+			ignored = !rule.negate
+		}
+	}
+	return matched, ignored
+}
+
+// compileIgnorePattern compiles a single line of gitignore syntax. The boolean
+// result is false for blank lines and comments.
+func compileIgnorePattern(line string) (ignoreRule, bool, error) {
+	line = trimIgnoreTrailingSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	var rule ignoreRule
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false, nil
+	}
+
+	// A slash anywhere but the end anchors the pattern to the ignore file's
+	// directory; otherwise it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := "^" + globToRegexp(line) + "$"
+	if !anchored {
+		expr = "^(?:.*/)?" + globToRegexp(line) + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false, fmt.Errorf("compileIgnorePattern(): invalid pattern %q: %w", line, err)
+	}
+	rule.re = re
+	return rule, true, nil
+}
+
+// trimIgnoreTrailingSpace removes trailing spaces unless they are escaped
+// with a backslash, as gitignore does.
+func trimIgnoreTrailingSpace(line string) string {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// globToRegexp translates a gitignore glob into a regular expression body.
+// "*" and "?" never match a slash, "**" matches across directories, and
+// character classes are passed through with "!" negation supported.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atSegmentStart := i == 0 || glob[i-1] == '/'
+				next := i + 2
+				switch {
+				case atSegmentStart && next < len(glob) && glob[next] == '/':
+					// "**/" matches zero or more directories.
+					b.WriteString("(?:.*/)?")
+					i = next
+				default:
+					// A trailing "/**" or any other "**" matches everything.
+					b.WriteString(".*")
+					i = next - 1
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// pathFilter decides which directories and files of a walk are skipped. It
+// combines the built-in defaults, .peekrignore files, optional .gitignore
+// files, and the include/exclude globs from Options.
+type pathFilter struct {
+	root     string
+	opts     Options
+	defaults *ignoreList
+	include  *ignoreList
+	exclude  *ignoreList
+	perDir   map[string][]*ignoreList // Ignore files found in each directory
+}
+
+// newPathFilter builds the filter for a walk starting at root.
+func newPathFilter(root string, opts Options) (*pathFilter, error) {
+	pf := &pathFilter{root: root, opts: opts, perDir: make(map[string][]*ignoreList)}
+
+	var err error
+	if !opts.NoIgnore {
+		if pf.defaults, err = newIgnoreList(root, defaultIgnorePatterns); err != nil {
+			return nil, err
+		}
+	}
+	if pf.exclude, err = newIgnoreList(root, opts.Exclude); err != nil {
+		return nil, err
+	}
+	if pf.include, err = newIgnoreList(root, opts.Include); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// skipDir reports whether the walk should not descend into dir.
+func (pf *pathFilter) skipDir(dir string) (bool, error) {
+	return pf.ignored(dir, true)
+}
+
+// skipFile reports whether file should not be parsed.
+func (pf *pathFilter) skipFile(file string) (bool, error) {
+	ignored, err := pf.ignored(file, false)
+	if err != nil || ignored {
+		return ignored, err
+	}
+	if len(pf.include.rules) > 0 {
+		matched, included := pf.include.match(file, false)
+		return !matched || !included, nil
+	}
+	return false, nil
+}
+
+// ignored evaluates every applicable rule for path. Later and deeper rules
+// win over earlier ones, mirroring git's precedence.
+func (pf *pathFilter) ignored(path string, isDir bool) (bool, error) {
+	lists := []*ignoreList{pf.defaults}
+	if !pf.opts.NoIgnore {
+		dirLists, err := pf.ancestorLists(filepath.Dir(path))
+		if err != nil {
+			return false, err
+		}
+		lists = append(lists, dirLists...)
+	}
+	lists = append(lists, pf.exclude)
+
+	ignored := false
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		if matched, ign := list.match(path, isDir); matched {
+			ignored = ign
+		}
+	}
+	return ignored, nil
+}
+
+// ancestorLists returns the ignore files of every directory from the root
+// down to dir, loading and caching them on first use.
+func (pf *pathFilter) ancestorLists(dir string) ([]*ignoreList, error) {
+	rel, err := filepath.Rel(pf.root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, nil
+	}
+
+	dirs := []string{pf.root}
+	if rel != "." {
+		current := pf.root
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			current = filepath.Join(current, part)
+			dirs = append(dirs, current)
+		}
+	}
+
+	var lists []*ignoreList
+	for _, d := range dirs {
+		cached, ok := pf.perDir[d]
+		if !ok {
+			names := []string{IgnoreFileName}
+			if pf.opts.GitIgnore {
+				names = []string{".gitignore", IgnoreFileName}
+			}
+			for _, name := range names {
+				list, err := loadIgnoreFile(d, name)
+				if err != nil {
+					return nil, err
+				}
+				if list != nil {
+					cached = append(cached, list)
+				}
+			}
+			pf.perDir[d] = cached
+		}
+		lists = append(lists, cached...)
+	}
+	return lists, nil
+}
@@ -0,0 +1,93 @@
+package peekr
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreListMatch(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pattern string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{name: "Name at any depth", pattern: "mocks", path: "a/b/mocks", isDir: true, ignored: true},
+		{name: "Star suffix", pattern: "*.pb.go", path: "api/v1/service.pb.go", ignored: true},
+		{name: "Star does not cross slash", pattern: "api/*.go", path: "api/v1/x.go", ignored: false},
+		{name: "Anchored", pattern: "/build", path: "sub/build", isDir: true, ignored: false},
+		{name: "Anchored match", pattern: "/build", path: "build", isDir: true, ignored: true},
+		{name: "Dir only skips files", pattern: "out/", path: "out", isDir: false, ignored: false},
+		{name: "Leading double star", pattern: "**/fixtures", path: "x/y/fixtures", isDir: true, ignored: true},
+		{name: "Trailing double star", pattern: "gen/**", path: "gen/a/b.go", ignored: true},
+		{name: "Middle double star", pattern: "a/**/z.go", path: "a/z.go", ignored: true},
+		{name: "Character class", pattern: "file[0-9].go", path: "file7.go", ignored: true},
+		{name: "Negated class", pattern: "file[!0-9].go", path: "file7.go", ignored: false},
+		{name: "Comment", pattern: "# mocks", path: "mocks", isDir: true, ignored: false},
+	}
+
+	root := filepath.FromSlash("/repo")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := newIgnoreList(root, []string{tc.pattern})
+			require.NoError(t, err)
+			_, ignored := list.match(filepath.Join(root, filepath.FromSlash(tc.path)), tc.isDir)
+			assert.Equal(t, tc.ignored, ignored)
+		})
+	}
+}
+
+func TestIgnoreListNegation(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	list, err := newIgnoreList(root, []string{"*.go", "!keep.go"})
+	require.NoError(t, err)
+
+	_, ignored := list.match(filepath.Join(root, "drop.go"), false)
+	assert.True(t, ignored)
+	_, ignored = list.match(filepath.Join(root, "keep.go"), false)
+	assert.False(t, ignored)
+}
+
+func TestSourceFilesIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":                  "module example.com/ign\n",
+		".peekrignore":            "# fixtures\nold/\n",
+		".gitignore":              "*_gen.go\n",
+		"pkg/a.go":                "package pkg\n",
+		"pkg/a_gen.go":            "package pkg\n",
+		"pkg/.peekrignore":        "local.go\n",
+		"pkg/local.go":            "package pkg\n",
+		"pkg/testdata/fixture.go": "package pkg\n",
+		"old/stale.go":            "package pkg\n",
+		"node_modules/x/x.go":     "package x\n",
+		"mocks/mock.go":           "package mocks\n",
+	})
+
+	files := func(opts Options) []string {
+		ws, err := LoadWorkspace(root, opts)
+		require.NoError(t, err)
+		paths, _, err := ws.sourceFiles(context.Background(), opts)
+		require.NoError(t, err)
+
+		var rel []string
+		for _, path := range paths {
+			r, err := filepath.Rel(root, path)
+			require.NoError(t, err)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		sort.Strings(rel)
+		return rel
+	}
+
+	assert.Equal(t, []string{"mocks/mock.go", "pkg/a.go", "pkg/a_gen.go"}, files(Options{}))
+	assert.Equal(t, []string{"mocks/mock.go", "pkg/a.go"}, files(Options{GitIgnore: true}))
+	assert.Equal(t, []string{"pkg/a.go", "pkg/a_gen.go"}, files(Options{Exclude: []string{"mocks"}}))
+	assert.Equal(t, []string{"pkg/a.go", "pkg/a_gen.go", "pkg/local.go"}, files(Options{Include: []string{"pkg/**"}, NoIgnore: true, Exclude: []string{"testdata"}}))
+}
@@ -0,0 +1,410 @@
+package peekr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// LineRange is a range of lines of a file, both ends included.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Changes maps absolute file paths to the lines changed in them.
+type Changes map[string][]LineRange
+
+// ImpactSymbol is a package-level declaration found by FindImpact.
+type ImpactSymbol struct {
+	Kind    string `json:"kind"`    // "func", "method", "type", "const" or "var"
+	Name    string `json:"name"`    // e.g. "helpers.TerminalColor" or "(*peekr.Options).workers"
+	Package string `json:"package"` // Import path of the declaring package
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+}
+
+// Impact is the result of FindImpact.
+type Impact struct {
+	Changed     []ImpactSymbol `json:"changed"`  // Declarations containing a changed line
+	Symbols     []ImpactSymbol `json:"symbols"`  // Exported declarations depending on a changed one
+	Packages    []string       `json:"packages"` // Import paths of the packages of changed and dependent declarations
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+}
+
+// ChangedLines returns the lines of the repository containing dir that
+// differ between the git revision since, "HEAD" when empty, and the working
+// tree, staged or not. Untracked files that are not ignored count as
+// entirely changed. Lines removed without replacement are attributed to the
+// line before them.
+func ChangedLines(ctx context.Context, dir, since string) (Changes, error) {
+	if since == "" {
+		since = "HEAD"
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ChangedLines(): %w", err)
+	}
+	cdup, err := runGit(ctx, absDir, "rev-parse", "--show-cdup")
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Join(absDir, strings.TrimSpace(string(cdup)))
+
+	diff, err := runGit(ctx, root, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", since, "--")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := parseUnifiedDiff(bytes.NewReader(diff), root)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := runGit(ctx, root, "ls-files", "--others", "--exclude-standard", "--full-name")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(strings.TrimSpace(string(untracked)), "\n") {
+		if name != "" {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			changes[path] = []LineRange{{Start: 1, End: math.MaxInt32}}
+		}
+	}
+	return changes, nil
+}
+
+// runGit runs git in dir and returns its standard output.
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("runGit(): git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// parseUnifiedDiff returns the lines added or modified by a diff produced
+// with --unified=0, keyed by the path of the new file joined to root.
+// Deleted files are skipped.
+func parseUnifiedDiff(r io.Reader, root string) (Changes, error) {
+	changes := make(Changes)
+	path := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			path = ""
+			if strings.HasPrefix(name, "b/") {
+				path = filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(name, "b/")))
+			}
+		case strings.HasPrefix(line, "@@ ") && path != "":
+			// @@ -start[,count] +start[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("parseUnifiedDiff(): malformed hunk header %q", line)
+			}
+			start, count := strings.TrimPrefix(fields[2], "+"), "1"
+			if i := strings.IndexByte(start, ','); i >= 0 {
+				start, count = start[:i], start[i+1:]
+			}
+			first, err := strconv.Atoi(start)
+			if err != nil {
+				return nil, fmt.Errorf("parseUnifiedDiff(): malformed hunk header %q", line)
+			}
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return nil, fmt.Errorf("parseUnifiedDiff(): malformed hunk header %q", line)
+			}
+			changed := LineRange{Start: first, End: first + n - 1}
+			if n == 0 {
+				changed = LineRange{Start: first, End: first}
+				if first == 0 {
+					changed = LineRange{Start: 1, End: 1}
+				}
+			}
+			changes[path] = append(changes[path], changed)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parseUnifiedDiff(): %w", err)
+	}
+	return changes, nil
+}
+
+// declaration is a package-level declaration of the workspace and the lines
+// it spans, doc comment included.
+type declaration struct {
+	obj         types.Object
+	file        string
+	start, end  int
+	node        ast.Node // The declaration, or its spec within a grouped declaration
+	info        *types.Info
+	packagePath string
+}
+
+// declarations returns the package-level declarations of every workspace
+// package, and the declaration owning every object declared inside a type,
+// such as struct fields and interface methods.
+func (p *program) declarations() ([]declaration, map[types.Object]types.Object) {
+	var decls []declaration
+	owners := make(map[types.Object]types.Object)
+	add := func(tp *typedPackage, obj types.Object, node ast.Node, doc *ast.CommentGroup) {
+		if obj == nil {
+			return
+		}
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		pos := p.fset.Position(start)
+		decls = append(decls, declaration{
+			obj:         obj,
+			file:        pos.Filename,
+			start:       pos.Line,
+			end:         p.fset.Position(node.End()).Line,
+			node:        node,
+			info:        tp.info,
+			packagePath: tp.path,
+		})
+	}
+
+	for _, tp := range p.workspacePackages() {
+		for _, file := range tp.files {
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					add(tp, tp.info.Defs[d.Name], d, d.Doc)
+				case *ast.GenDecl:
+					if d.Tok == token.IMPORT {
+						continue
+					}
+					for _, spec := range d.Specs {
+						node, doc := ast.Node(d), d.Doc
+						if d.Lparen.IsValid() {
+							node, doc = spec, nil
+						}
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							if s.Doc != nil {
+								doc = s.Doc
+							}
+							obj := tp.info.Defs[s.Name]
+							add(tp, obj, node, doc)
+							ast.Inspect(s.Type, func(n ast.Node) bool {
+								if ident, ok := n.(*ast.Ident); ok && tp.info.Defs[ident] != nil {
+									owners[tp.info.Defs[ident]] = obj
+								}
+								return true
+							})
+						case *ast.ValueSpec:
+							if s.Doc != nil {
+								doc = s.Doc
+							}
+							for _, name := range s.Names {
+								add(tp, tp.info.Defs[name], node, doc)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls, owners
+}
+
+// FindImpact type-checks every package of the workspace containing opts.Dir,
+// finds the package-level declarations containing a changed line, and
+// follows references backwards to every declaration that depends on them,
+// directly or transitively. Uses of struct fields and interface methods are
+// attributed to the type declaring them. Calls through an interface are not
+// followed to the methods implementing it, and declarations removed by the
+// changes cannot be found. Declarations of test files are never reported as
+// dependents, but their packages are listed.
+func FindImpact(ctx context.Context, opts Options, changes Changes) (*Impact, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("FindImpact(): Options.Dir is required")
+	}
+	prog, err := loadProgram(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	absChanges := make(Changes, len(changes))
+	for path, ranges := range changes {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		absChanges[path] = append(absChanges[path], ranges...)
+	}
+
+	decls, owners := prog.declarations()
+	byObject := make(map[types.Object]declaration, len(decls))
+	dependents := make(map[types.Object][]types.Object)
+	for _, decl := range decls {
+		byObject[decl.obj] = decl
+		ast.Inspect(decl.node, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok || decl.info.Uses[ident] == nil {
+				return true
+			}
+			used := originObject(decl.info.Uses[ident])
+			if owner, ok := owners[used]; ok {
+				used = owner
+			}
+			if used != decl.obj && used.Pkg() != nil && prog.inWorkspace(used.Pkg()) {
+				dependents[used] = append(dependents[used], decl.obj)
+			}
+			return true
+		})
+	}
+
+	impact := &Impact{Diagnostics: prog.diagnostics}
+	seen := make(map[types.Object]bool)
+	var queue []types.Object
+	for _, decl := range decls {
+		for _, changed := range absChanges[decl.file] {
+			if changed.Start <= decl.end && changed.End >= decl.start && !seen[decl.obj] {
+				seen[decl.obj] = true
+				queue = append(queue, decl.obj)
+				impact.Changed = append(impact.Changed, prog.impactSymbol(decl))
+			}
+		}
+	}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[obj] {
+			if seen[dependent] {
+				continue
+			}
+			seen[dependent] = true
+			queue = append(queue, dependent)
+			if decl := byObject[dependent]; isAPI(dependent) && !isTestFile(decl.file) {
+				impact.Symbols = append(impact.Symbols, prog.impactSymbol(decl))
+			}
+		}
+	}
+
+	packages := make(map[string]bool)
+	for obj := range seen {
+		packages[byObject[obj].packagePath] = true
+	}
+	for path := range packages {
+		impact.Packages = append(impact.Packages, path)
+	}
+	sort.Strings(impact.Packages)
+	sortImpactSymbols(impact.Changed)
+	sortImpactSymbols(impact.Symbols)
+	return impact, nil
+}
+
+// isAPI reports whether obj is part of the exported API of its package: an
+// exported declaration, or an exported method of an exported type.
+func isAPI(obj types.Object) bool {
+	if !obj.Exported() {
+		return false
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return true
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return true
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return !ok || named.Obj().Exported()
+}
+
+// impactSymbol describes decl.
+func (p *program) impactSymbol(decl declaration) ImpactSymbol {
+	symbol := ImpactSymbol{Name: decl.obj.Pkg().Name() + "." + decl.obj.Name(), Package: decl.packagePath, File: decl.file, Line: p.fset.Position(decl.obj.Pos()).Line}
+	switch obj := decl.obj.(type) {
+	case *types.Func:
+		symbol.Kind, symbol.Name = "func", funcDisplayName(obj)
+		if obj.Type().(*types.Signature).Recv() != nil {
+			symbol.Kind = "method"
+		}
+	case *types.TypeName:
+		symbol.Kind = "type"
+	case *types.Const:
+		symbol.Kind = "const"
+	default:
+		symbol.Kind = "var"
+	}
+	return symbol
+}
+
+// sortImpactSymbols sorts symbols by file and line.
+func sortImpactSymbols(symbols []ImpactSymbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].File != symbols[j].File {
+			return symbols[i].File < symbols[j].File
+		}
+		return symbols[i].Line < symbols[j].Line
+	})
+}
+
+// PrintImpact prints the result of FindImpact.
+func PrintImpact(impact *Impact) {
+	writeImpact(os.Stdout, impact)
+}
+
+// writeImpact writes the changed declarations of impact to w, followed by
+// the exported symbols and packages depending on them.
+func writeImpact(w io.Writer, impact *Impact) {
+	if len(impact.Changed) == 0 {
+		helpers.FprintColor(w, "\nNo Go declarations changed.", helpers.Debug)
+		return
+	}
+	writeSymbols := func(symbols []ImpactSymbol, level helpers.ErrorLevel) {
+		width := 0
+		for _, symbol := range symbols {
+			if n := len(symbol.Kind) + 1 + len(symbol.Name); n > width {
+				width = n
+			}
+		}
+		for _, symbol := range symbols {
+			helpers.FprintColor(w, fmt.Sprintf("  %-*s  %s:%d", width, symbol.Kind+" "+symbol.Name, symbol.File, symbol.Line), level)
+		}
+	}
+
+	helpers.FprintColor(w, fmt.Sprintf("\n%d changed declaration(s):\n", len(impact.Changed)), helpers.Info)
+	writeSymbols(impact.Changed, helpers.Warn)
+
+	if len(impact.Symbols) == 0 {
+		helpers.FprintColor(w, "\nNo exported symbols depend on them.", helpers.Debug)
+	} else {
+		helpers.FprintColor(w, fmt.Sprintf("\n%d exported symbol(s) depend on them:\n", len(impact.Symbols)), helpers.Info)
+		writeSymbols(impact.Symbols, helpers.Debug)
+	}
+
+	helpers.FprintColor(w, fmt.Sprintf("\n%d impacted package(s):\n", len(impact.Packages)), helpers.Info)
+	for _, path := range impact.Packages {
+		helpers.FprintColor(w, "  "+path, helpers.Cyan)
+	}
+}
@@ -0,0 +1,149 @@
+package peekr
+
+import (
+	"context"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/peekr/a.go b/peekr/a.go
+index 1111111..2222222 100644
+--- a/peekr/a.go
++++ b/peekr/a.go
+@@ -3 +3 @@ package peekr
+-var A = 1
++var A = 2
+@@ -10,0 +11,3 @@ func F() {
++	x := 1
++	_ = x
++
+@@ -20,2 +22,0 @@ func G() {
+-	y := 2
+-	_ = y
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package old
+`
+	changes, err := parseUnifiedDiff(strings.NewReader(diff), "/repo")
+	require.NoError(t, err)
+	assert.Equal(t, Changes{
+		filepath.Join("/repo", "peekr", "a.go"): {{Start: 3, End: 3}, {Start: 11, End: 13}, {Start: 22, End: 22}},
+	}, changes)
+
+	_, err = parseUnifiedDiff(strings.NewReader("+++ b/a.go\n@@ -1 +x @@\n"), "/repo")
+	assert.Error(t, err)
+}
+
+func TestFindImpact(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/impact\n",
+		"util/util.go": `package util
+
+// Config is shared.
+type Config struct {
+	Name string
+}
+
+func clean(s string) string { return s }
+
+// Normalize cleans a name.
+func Normalize(s string) string { return clean(s) }
+
+// Unrelated is not affected.
+func Unrelated() {}
+`,
+		"api/api.go": `package api
+
+import "example.com/impact/util"
+
+// Handler uses a Config.
+type Handler struct{ cfg util.Config }
+
+// Title reads a field of Config.
+func (h Handler) Title() string { return util.Normalize(h.cfg.Name) }
+
+func helper() string { return Handler{}.Title() }
+
+// Greeting depends on Title through an unexported helper.
+var Greeting = helper()
+`,
+		"other/other.go": "package other\n\nimport \"example.com/impact/util\"\n\nfunc Other() { util.Unrelated() }\n",
+	})
+
+	names := func(symbols []ImpactSymbol) []string {
+		var out []string
+		for _, symbol := range symbols {
+			out = append(out, symbol.Kind+" "+symbol.Name)
+		}
+		return out
+	}
+
+	t.Run("Function body", func(t *testing.T) {
+		impact, err := FindImpact(context.Background(), Options{Dir: root}, Changes{
+			filepath.Join(root, "util", "util.go"): {{Start: 8, End: 8}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"func util.clean"}, names(impact.Changed))
+		assert.Equal(t, []string{"method (api.Handler).Title", "var api.Greeting", "func util.Normalize"}, names(impact.Symbols))
+		assert.Equal(t, []string{"example.com/impact/api", "example.com/impact/util"}, impact.Packages)
+	})
+
+	t.Run("Struct field and doc comment", func(t *testing.T) {
+		impact, err := FindImpact(context.Background(), Options{Dir: root}, Changes{
+			filepath.Join(root, "util", "util.go"): {{Start: 3, End: 3}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"type util.Config"}, names(impact.Changed))
+		assert.Equal(t, []string{"type api.Handler", "method (api.Handler).Title", "var api.Greeting"}, names(impact.Symbols))
+	})
+
+	t.Run("Outside any declaration", func(t *testing.T) {
+		impact, err := FindImpact(context.Background(), Options{Dir: root}, Changes{
+			filepath.Join(root, "api", "api.go"): {{Start: 2, End: 3}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, impact.Changed)
+		assert.Empty(t, impact.Packages)
+	})
+}
+
+func TestChangedLines(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=peekr", "-c", "user.email=peekr@example.com"}, args...)...)
+		cmd.Dir = root
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	writeTree(t, root, map[string]string{"pkg/a.go": "package pkg\n\nvar A = 1\n"})
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pkg", "a.go"), []byte("package pkg\n\nvar A = 2\n"), 0o644))
+	writeTree(t, root, map[string]string{"pkg/b.go": "package pkg\n"})
+
+	changes, err := ChangedLines(context.Background(), filepath.Join(root, "pkg"), "")
+	require.NoError(t, err)
+	assert.Equal(t, []LineRange{{Start: 3, End: 3}}, changes[filepath.Join(root, "pkg", "a.go")])
+	assert.Equal(t, []LineRange{{Start: 1, End: math.MaxInt32}}, changes[filepath.Join(root, "pkg", "b.go")])
+
+	_, err = ChangedLines(context.Background(), root, "no-such-revision")
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package peekr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// Implementation is a type related to the query of FindImplementations: a
+// concrete type implementing the queried interface, or an interface the
+// queried type satisfies.
+type Implementation struct {
+	Name    string `json:"name"`           // Package-qualified name, e.g. "example.com/mod/pkg.Type"
+	Pointer bool   `json:"pointer"`        // Only the pointer type satisfies the interface
+	File    string `json:"file,omitempty"` // Declaring file; empty for predeclared types such as error
+	Line    int    `json:"line,omitempty"` // 1-based line of the declaration
+}
+
+// Implementations is the result of FindImplementations.
+type Implementations struct {
+	Query       string           `json:"query"`     // Package-qualified name of the queried type
+	Interface   bool             `json:"interface"` // Whether the query is an interface
+	Matches     []Implementation `json:"matches"`   // Sorted by name
+	Diagnostics []Diagnostic     `json:"diagnostics,omitempty"`
+}
+
+// interfacePackages are the standard library packages whose interfaces
+// FindImplementations always considers, whether or not the workspace
+// imports them.
+var interfacePackages = []string{
+	"container/heap",
+	"context",
+	"encoding",
+	"encoding/json",
+	"encoding/xml",
+	"flag",
+	"fmt",
+	"hash",
+	"io",
+	"io/fs",
+	"sort",
+}
+
+// FindImplementations type-checks every package of the workspace containing
+// opts.Dir and relates the type called name ("pkg.Type", by package name or
+// import path, or a predeclared type such as "error") to the others. For an
+// interface, it returns every concrete type of the workspace that satisfies
+// it, with either a value or a pointer receiver. For any other type, it
+// returns every interface it satisfies among those of the workspace, the
+// packages it imports, the common standard library packages listed in
+// interfacePackages, and error. Interfaces without methods, which every type
+// satisfies, are left out.
+func FindImplementations(ctx context.Context, opts Options, name string) (*Implementations, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("FindImplementations(): Options.Dir is required")
+	}
+	prog, err := loadProgram(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	query, err := prog.lookupType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Implementations{Query: qualifiedName(query), Diagnostics: prog.diagnostics}
+	if iface, ok := query.Type().Underlying().(*types.Interface); ok {
+		result.Interface = true
+		if named, ok := query.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("FindImplementations(): %s is generic, which is not supported", result.Query)
+		}
+		for _, tp := range prog.packages {
+			for _, obj := range namedTypes(tp.types, false) {
+				if _, isIface := obj.Type().Underlying().(*types.Interface); isIface {
+					continue
+				}
+				if pointer, ok := satisfies(obj.Type(), iface); ok {
+					result.Matches = append(result.Matches, prog.implementation(obj, pointer))
+				}
+			}
+		}
+	} else {
+		packages := prog.allPackages()
+		for _, path := range interfacePackages {
+			if pkg, err := prog.importer.Import(path); err == nil {
+				packages = append(packages, pkg)
+			}
+		}
+		var candidates []*types.TypeName
+		candidates = append(candidates, types.Universe.Lookup("error").(*types.TypeName))
+		seen := make(map[*types.Package]bool)
+		for _, pkg := range packages {
+			if !seen[pkg] {
+				seen[pkg] = true
+				candidates = append(candidates, namedTypes(pkg, !prog.inWorkspace(pkg))...)
+			}
+		}
+		for _, obj := range candidates {
+			iface, ok := obj.Type().Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() == 0 || !iface.IsMethodSet() {
+				continue
+			}
+			if pointer, ok := satisfies(query.Type(), iface); ok {
+				result.Matches = append(result.Matches, prog.implementation(obj, pointer))
+			}
+		}
+	}
+
+	sort.Slice(result.Matches, func(i, j int) bool {
+		return result.Matches[i].Name < result.Matches[j].Name
+	})
+	return result, nil
+}
+
+// namedTypes returns the non-generic, non-alias types declared at the top
+// level of pkg, only the exported ones if exported is set.
+func namedTypes(pkg *types.Package, exported bool) []*types.TypeName {
+	var names []*types.TypeName
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() || (exported && !obj.Exported()) {
+			continue
+		}
+		if named, ok := obj.Type().(*types.Named); !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		names = append(names, obj)
+	}
+	return names
+}
+
+// satisfies reports whether t implements iface, and whether only the
+// pointer type *t does.
+func satisfies(t types.Type, iface *types.Interface) (pointer, ok bool) {
+	switch {
+	case types.Implements(t, iface):
+		return false, true
+	case types.Implements(types.NewPointer(t), iface):
+		return true, true
+	}
+	return false, false
+}
+
+// implementation describes the type obj as a match.
+func (p *program) implementation(obj *types.TypeName, pointer bool) Implementation {
+	match := Implementation{Name: qualifiedName(obj), Pointer: pointer}
+	if obj.Pkg() != nil {
+		pos := p.fset.Position(obj.Pos())
+		match.File, match.Line = pos.Filename, pos.Line
+	}
+	return match
+}
+
+// PrintImplementations prints the result of FindImplementations.
+func PrintImplementations(result *Implementations) {
+	writeImplementations(os.Stdout, result)
+}
+
+// writeImplementations writes the matches of result to w, one per line with
+// the location of their declaration.
+func writeImplementations(w io.Writer, result *Implementations) {
+	var header string
+	switch {
+	case result.Interface && len(result.Matches) == 0:
+		header = fmt.Sprintf("\nNo types implement '%s'.", result.Query)
+	case result.Interface:
+		header = fmt.Sprintf("\nTypes implementing '%s':\n", result.Query)
+	case len(result.Matches) == 0:
+		header = fmt.Sprintf("\n'%s' satisfies no interfaces.", result.Query)
+	default:
+		header = fmt.Sprintf("\nInterfaces satisfied by '%s':\n", result.Query)
+	}
+	if len(result.Matches) == 0 {
+		helpers.FprintColor(w, header, helpers.Error)
+		return
+	}
+	helpers.FprintColor(w, header, helpers.Info)
+
+	// A pointer match is the concrete type *T when the query is an interface,
+	// and the interface satisfied by *Query otherwise.
+	labels := make([]string, len(result.Matches))
+	width := 0
+	for i, match := range result.Matches {
+		labels[i] = match.Name
+		switch {
+		case match.Pointer && result.Interface:
+			labels[i] = "*" + match.Name
+		case match.Pointer:
+			labels[i] = match.Name + " (pointer receiver)"
+		}
+		if len(labels[i]) > width {
+			width = len(labels[i])
+		}
+	}
+	for i, match := range result.Matches {
+		line := "  " + labels[i]
+		if match.File != "" {
+			line = fmt.Sprintf("  %-*s  %s:%d", width, labels[i], match.File, match.Line)
+		}
+		helpers.FprintColor(w, line, helpers.Debug)
+	}
+}
@@ -0,0 +1,89 @@
+package peekr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindImplementations(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/impl\n",
+		"shape/shape.go": `package shape
+
+type Shape interface {
+	Area() float64
+}
+
+type Square struct{ Side float64 }
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+
+func (s Square) String() string { return "square" }
+`,
+		"circle/circle.go": `package circle
+
+type Circle struct{ R float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.R * c.R }
+
+func (c *Circle) Error() string { return "circle" }
+
+type Point struct{}
+`,
+	})
+	opts := Options{Dir: root}
+
+	t.Run("Interface", func(t *testing.T) {
+		result, err := FindImplementations(context.Background(), opts, "shape.Shape")
+		require.NoError(t, err)
+		assert.True(t, result.Interface)
+		assert.Equal(t, "example.com/impl/shape.Shape", result.Query)
+		require.Len(t, result.Matches, 2)
+		assert.Equal(t, "example.com/impl/circle.Circle", result.Matches[0].Name)
+		assert.True(t, result.Matches[0].Pointer)
+		assert.Equal(t, "example.com/impl/shape.Square", result.Matches[1].Name)
+		assert.False(t, result.Matches[1].Pointer)
+		assert.Equal(t, 7, result.Matches[1].Line)
+	})
+
+	t.Run("Type", func(t *testing.T) {
+		result, err := FindImplementations(context.Background(), opts, "example.com/impl/circle.Circle")
+		require.NoError(t, err)
+		assert.False(t, result.Interface)
+		var names []string
+		for _, match := range result.Matches {
+			names = append(names, match.Name)
+			assert.True(t, match.Pointer)
+		}
+		assert.Equal(t, []string{"error", "example.com/impl/shape.Shape"}, names)
+	})
+
+	t.Run("Stdlib interface", func(t *testing.T) {
+		// Common stdlib interfaces are found even when no package of the
+		// workspace imports their package.
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{
+			"go.mod":       "module example.com/stringer\n",
+			"name/name.go": "package name\n\ntype Name string\n\nfunc (n Name) String() string { return string(n) }\n\nfunc (n Name) Read(p []byte) (int, error) { return copy(p, n), nil }\n",
+		})
+		result, err := FindImplementations(context.Background(), Options{Dir: root}, "name.Name")
+		require.NoError(t, err)
+		var names []string
+		for _, match := range result.Matches {
+			names = append(names, match.Name)
+		}
+		assert.Contains(t, names, "fmt.Stringer")
+		assert.Contains(t, names, "io.Reader")
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := FindImplementations(context.Background(), opts, "shape.Missing")
+		assert.ErrorContains(t, err, `"shape.Missing" not found`)
+		_, err = FindImplementations(context.Background(), opts, "Square")
+		assert.ErrorContains(t, err, "must be qualified")
+	})
+}
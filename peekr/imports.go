@@ -0,0 +1,509 @@
+package peekr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// ImportGraphFormats lists the formats WriteImportGraph supports.
+var ImportGraphFormats = []string{"text", "dot", "mermaid", "json"}
+
+// ImportGraphOptions controls what BuildImportGraph includes.
+type ImportGraphOptions struct {
+	// Patterns select the importing packages, like the package patterns of
+	// the go command: "./..." for every package under Options.Dir, "./cmd"
+	// for a single directory, or import paths such as "example.com/mod/...".
+	// No patterns means "./...".
+	Patterns []string
+
+	// External adds the modules outside the workspace that the packages
+	// import, one node per module. The standard library is never included.
+	External bool
+}
+
+// ImportNode is a package, or an external module, in an import graph.
+type ImportNode struct {
+	Path     string `json:"path"`               // Import path, or module path for external nodes
+	Name     string `json:"name,omitempty"`     // Package name; empty for packages outside the patterns
+	Dir      string `json:"dir,omitempty"`      // Package directory; empty for external nodes
+	External bool   `json:"external,omitempty"` // Whether the node is a module outside the workspace
+	FanIn    int    `json:"fanIn"`              // Number of nodes importing this one
+	FanOut   int    `json:"fanOut"`             // Number of nodes this one imports
+	InCycle  bool   `json:"inCycle,omitempty"`  // Whether the package is part of an import cycle
+}
+
+// ImportEdge is an import of one node by another. Several imports between
+// the same packages are recorded once, at the first import declaration.
+type ImportEdge struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	File    string `json:"file"` // Position of the first import declaration
+	Line    int    `json:"line"`
+	InCycle bool   `json:"inCycle,omitempty"` // Whether the import is part of an import cycle
+}
+
+// ImportGraph is the import graph of the workspace packages. Nodes are
+// sorted by path and edges by importer and imported path.
+type ImportGraph struct {
+	Nodes       []ImportNode `json:"nodes"`
+	Edges       []ImportEdge `json:"edges"`
+	Cycles      [][]string   `json:"cycles,omitempty"` // Each cycle as a path a, b, c meaning a imports b, b imports c and c imports a
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// importSite is a single import declaration in a workspace package.
+type importSite struct {
+	from string // Import path of the importing package
+	to   string // Imported path, as written
+	file string
+	line int
+}
+
+// importScan holds the import declarations of the workspace packages
+// matched by a set of patterns.
+type importScan struct {
+	ws          *Workspace
+	packages    map[string]ImportNode // Matched packages by import path
+	sites       []importSite          // In file and line order
+	diagnostics []Diagnostic
+}
+
+// scanImports parses the workspace containing opts.Dir and collects the
+// import declarations of the packages matched by patterns. Only files that
+// are part of the build are read when opts names a build target. With
+// opts.Tests, the imports of _test.go files count too, and an external test
+// package is a node of its own, named after its package with a "_test"
+// suffix.
+func scanImports(ctx context.Context, opts Options, patterns []string) (*importScan, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	baseDir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("scanImports(): %w", err)
+	}
+	ws, err := LoadWorkspace(baseDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	walkOpts := opts
+	walkOpts.Vendor = false
+	files, diags, err := ws.sourceFiles(ctx, walkOpts)
+	if err != nil {
+		return nil, err
+	}
+	if files, err = filterBuildConstraints(files, opts); err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	parsed, err := parseFiles(ctx, fset, files, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &importScan{ws: ws, packages: make(map[string]ImportNode)}
+	for _, pf := range parsed {
+		diags = append(diags, pf.diagnostics...)
+		if pf.file == nil || pf.file.Name == nil {
+			continue
+		}
+		dir := filepath.Dir(pf.path)
+		path, ok := ws.importPathOf(dir)
+		if !ok {
+			path = filepath.ToSlash(dir)
+		}
+		name := pf.file.Name.Name
+		if isTestFile(pf.path) && strings.HasSuffix(name, "_test") {
+			path += "_test"
+		}
+		if !matchesAnyPattern(patterns, baseDir, dir, path) {
+			continue
+		}
+		if _, ok := scan.packages[path]; !ok {
+			scan.packages[path] = ImportNode{Path: path, Name: name, Dir: dir}
+		}
+		for _, imp := range pf.file.Imports {
+			to, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || to == "C" {
+				continue
+			}
+			scan.sites = append(scan.sites, importSite{from: path, to: to, file: pf.path, line: fset.Position(imp.Pos()).Line})
+		}
+	}
+	scan.diagnostics = sortDiagnostics(diags)
+	return scan, nil
+}
+
+// matchesAnyPattern reports whether the package in dir, with the given
+// import path, is matched by one of patterns. Patterns starting with "." or
+// absolute paths are directories, relative to baseDir; anything else is an
+// import path. A "/..." suffix also matches everything below.
+func matchesAnyPattern(patterns []string, baseDir, dir, importPath string) bool {
+	importPath = strings.TrimSuffix(importPath, "_test")
+	for _, pattern := range patterns {
+		recursive := pattern == "..." || strings.HasSuffix(pattern, "/...")
+		prefix := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+
+		if strings.HasPrefix(pattern, ".") || filepath.IsAbs(pattern) {
+			target := prefix
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(baseDir, target)
+			}
+			rel, err := filepath.Rel(target, dir)
+			if err == nil && (rel == "." || (recursive && !strings.HasPrefix(rel, ".."))) {
+				return true
+			}
+			continue
+		}
+		if importPath == prefix || (recursive && (prefix == "" || strings.HasPrefix(importPath, prefix+"/"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStandardImport reports whether an import path belongs to the standard
+// library, whose paths never have a dot in their first element.
+func isStandardImport(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// BuildImportGraph parses the workspace containing opts.Dir and builds the
+// import graph of the packages matched by igOpts.Patterns, including the
+// other workspace packages they import. Import cycles, which break builds,
+// are detected and reported with one path per set of packages importing
+// each other.
+func BuildImportGraph(ctx context.Context, opts Options, igOpts ImportGraphOptions) (*ImportGraph, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("BuildImportGraph(): Options.Dir is required")
+	}
+	scan, err := scanImports(ctx, opts, igOpts.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	if len(scan.packages) == 0 {
+		return nil, fmt.Errorf("BuildImportGraph(): no packages match %s", strings.Join(igOpts.Patterns, " "))
+	}
+
+	var requires []string
+	if igOpts.External {
+		for _, mod := range scan.ws.Modules {
+			modRequires, err := parseModuleRequires(filepath.Join(mod.Dir, "go.mod"))
+			if err != nil {
+				return nil, err
+			}
+			requires = append(requires, modRequires...)
+		}
+	}
+
+	nodes := make(map[string]ImportNode)
+	for path, node := range scan.packages {
+		nodes[path] = node
+	}
+	edges := make(map[[2]string]ImportEdge)
+	for _, site := range scan.sites {
+		to := site.to
+		if _, ok := scan.ws.ModuleFor(to); ok {
+			if _, ok := nodes[to]; !ok {
+				dir, _ := scan.ws.resolveImportPath(to, opts)
+				nodes[to] = ImportNode{Path: to, Dir: dir}
+			}
+		} else if igOpts.External && !isStandardImport(to) {
+			to = externalModule(to, requires)
+			nodes[to] = ImportNode{Path: to, External: true}
+		} else {
+			continue
+		}
+		key := [2]string{site.from, to}
+		if _, ok := edges[key]; !ok {
+			edges[key] = ImportEdge{From: site.from, To: to, File: site.file, Line: site.line}
+		}
+	}
+
+	graph := &ImportGraph{Diagnostics: scan.diagnostics}
+	for _, edge := range edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	graph.Cycles = importCycles(graph.Edges)
+	inCycle := make(map[[2]string]bool)
+	cyclic := make(map[string]bool)
+	for _, cycle := range graph.Cycles {
+		for i, path := range cycle {
+			inCycle[[2]string{path, cycle[(i+1)%len(cycle)]}] = true
+			cyclic[path] = true
+		}
+	}
+	for i := range graph.Edges {
+		edge := &graph.Edges[i]
+		edge.InCycle = inCycle[[2]string{edge.From, edge.To}]
+		from, to := nodes[edge.From], nodes[edge.To]
+		from.FanOut++
+		to.FanIn++
+		nodes[edge.From], nodes[edge.To] = from, to
+	}
+	for path, node := range nodes {
+		node.InCycle = cyclic[path]
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].Path < graph.Nodes[j].Path })
+	return graph, nil
+}
+
+// externalModule returns the module among requires providing an import
+// path, preferring the longest match, or the import path itself when no
+// required module matches.
+func externalModule(importPath string, requires []string) string {
+	best := ""
+	for _, mod := range requires {
+		if (importPath == mod || strings.HasPrefix(importPath, mod+"/")) && len(mod) > len(best) {
+			best = mod
+		}
+	}
+	if best == "" {
+		return importPath
+	}
+	return best
+}
+
+// importCycles finds the strongly connected components of the graph formed
+// by edges and returns one cycle through each component with more than one
+// package, or with a package importing itself. Each cycle starts at the
+// smallest path of its component and is as short as possible.
+func importCycles(edges []ImportEdge) [][]string {
+	adjacent := make(map[string][]string)
+	var paths []string
+	seen := make(map[string]bool)
+	for _, edge := range edges {
+		adjacent[edge.From] = append(adjacent[edge.From], edge.To)
+		for _, path := range []string{edge.From, edge.To} {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	// Tarjan's algorithm.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+	var connect func(path string)
+	connect = func(path string) {
+		index[path] = len(index)
+		low[path] = index[path]
+		stack = append(stack, path)
+		onStack[path] = true
+		for _, next := range adjacent[path] {
+			if _, visited := index[next]; !visited {
+				connect(next)
+				if low[next] < low[path] {
+					low[path] = low[next]
+				}
+			} else if onStack[next] && index[next] < low[path] {
+				low[path] = index[next]
+			}
+		}
+		if low[path] != index[path] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == path {
+				break
+			}
+		}
+		components = append(components, component)
+	}
+	for _, path := range paths {
+		if _, visited := index[path]; !visited {
+			connect(path)
+		}
+	}
+
+	var cycles [][]string
+	for _, component := range components {
+		sort.Strings(component)
+		start := component[0]
+		if len(component) == 1 && !helpers.SliceContains(adjacent[start], start) {
+			continue
+		}
+		members := make(map[string]bool)
+		for _, path := range component {
+			members[path] = true
+		}
+		cycles = append(cycles, shortestCycle(start, adjacent, members))
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// shortestCycle returns the shortest path from start back to itself that
+// only goes through members, without repeating start at the end.
+func shortestCycle(start string, adjacent map[string][]string, members map[string]bool) []string {
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		next := append([]string(nil), adjacent[path]...)
+		sort.Strings(next)
+		for _, to := range next {
+			if to == start {
+				cycle := []string{path}
+				for cycle[0] != start {
+					cycle = append([]string{previous[cycle[0]]}, cycle...)
+				}
+				return cycle
+			}
+			if _, ok := previous[to]; !ok && members[to] {
+				previous[to] = path
+				queue = append(queue, to)
+			}
+		}
+	}
+	return []string{start}
+}
+
+// WriteImportGraph writes graph to w in one of the ImportGraphFormats:
+// "text" for a color-coded listing, "dot" for Graphviz, "mermaid" for a
+// Mermaid flowchart, or "json" for the ImportGraph itself. Import cycles are
+// drawn in red.
+func WriteImportGraph(w io.Writer, graph *ImportGraph, format string) error {
+	switch format {
+	case "text":
+		writeImportGraphText(w, graph)
+		return nil
+	case "dot":
+		return writeImportGraphDOT(w, graph)
+	case "mermaid":
+		return writeImportGraphMermaid(w, graph)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	}
+	return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(ImportGraphFormats, ", "))
+}
+
+// writeImportGraphText lists every package with its fan-in, fan-out and
+// imports, followed by the import cycles.
+func writeImportGraphText(w io.Writer, graph *ImportGraph) {
+	imports := make(map[string][]ImportEdge)
+	for _, edge := range graph.Edges {
+		imports[edge.From] = append(imports[edge.From], edge)
+	}
+
+	helpers.FprintColor(w, fmt.Sprintf("\nImport graph of %d package(s):", len(graph.Nodes)), helpers.Info)
+	for _, node := range graph.Nodes {
+		level := helpers.Cyan
+		if node.InCycle {
+			level = helpers.Error
+		}
+		label := node.Path
+		if node.External {
+			label += " [external]"
+		}
+		helpers.FprintColor(w, fmt.Sprintf("\n%s (fan-in %d, fan-out %d)", label, node.FanIn, node.FanOut), level)
+		for _, edge := range imports[node.Path] {
+			level := helpers.Debug
+			if edge.InCycle {
+				level = helpers.Error
+			}
+			helpers.FprintColor(w, "  -> "+edge.To, level)
+		}
+	}
+
+	if len(graph.Cycles) == 0 {
+		helpers.FprintColor(w, "\nNo import cycles.", helpers.Debug)
+		return
+	}
+	helpers.FprintColor(w, fmt.Sprintf("\n%d import cycle(s):", len(graph.Cycles)), helpers.Error)
+	for _, cycle := range graph.Cycles {
+		helpers.FprintColor(w, "  "+strings.Join(append(cycle, cycle[0]), " -> "), helpers.Error)
+	}
+}
+
+// writeImportGraphDOT writes graph as a Graphviz digraph. External modules
+// are drawn with dashed boxes.
+func writeImportGraphDOT(w io.Writer, graph *ImportGraph) error {
+	var sb strings.Builder
+	sb.WriteString("digraph imports {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, node := range graph.Nodes {
+		var attrs []string
+		if node.External {
+			attrs = append(attrs, "style=dashed")
+		}
+		if node.InCycle {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(&sb, "\t%s", strconv.Quote(node.Path))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	for _, edge := range graph.Edges {
+		style := ""
+		if edge.InCycle {
+			style = " [color=red]"
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s%s;\n", strconv.Quote(edge.From), strconv.Quote(edge.To), style)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeImportGraphMermaid writes graph as a Mermaid flowchart. Nodes get
+// generated IDs, since import paths are not valid Mermaid identifiers.
+func writeImportGraphMermaid(w io.Writer, graph *ImportGraph) error {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	ids := make(map[string]string)
+	for i, node := range graph.Nodes {
+		ids[node.Path] = fmt.Sprintf("n%d", i)
+		shape := "[\"%s\"]"
+		if node.External {
+			shape = "([\"%s\"])"
+		}
+		fmt.Fprintf(&sb, "    %s"+shape+"\n", ids[node.Path], node.Path)
+	}
+	var cycleLinks []string
+	for i, edge := range graph.Edges {
+		fmt.Fprintf(&sb, "    %s --> %s\n", ids[edge.From], ids[edge.To])
+		if edge.InCycle {
+			cycleLinks = append(cycleLinks, strconv.Itoa(i))
+		}
+	}
+	if len(cycleLinks) > 0 {
+		fmt.Fprintf(&sb, "    linkStyle %s stroke:red,stroke-width:2px\n", strings.Join(cycleLinks, ","))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,91 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildImportGraph(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":   "module example.com/ig\n\nrequire (\n\tgithub.com/spf13/cobra v1.7.0\n)\n",
+		"a/a.go":   "package a\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/ig/b\"\n)\n\nvar _ = fmt.Sprint(b.B)\n",
+		"b/b.go":   "package b\n\nimport \"example.com/ig/c\"\n\nvar B = c.C\n",
+		"c/c.go":   "package c\n\nimport \"example.com/ig/a\"\n\nvar C = a.A\n",
+		"c/c2.go":  "package c\n\nimport \"github.com/spf13/cobra/doc\"\n\nvar _ = doc.GenMarkdown\n",
+		"d/d.go":   "package d\n\nimport \"example.com/ig/a\"\n",
+		"a/a_x.go": "package a\n\nvar A = 1\n",
+	})
+
+	graph, err := BuildImportGraph(context.Background(), Options{Dir: root}, ImportGraphOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"example.com/ig/a", "example.com/ig/b", "example.com/ig/c"}}, graph.Cycles)
+	require.Len(t, graph.Edges, 4)
+	assert.Equal(t, ImportEdge{From: "example.com/ig/a", To: "example.com/ig/b", File: graph.Edges[0].File, Line: 6, InCycle: true}, graph.Edges[0])
+	assert.False(t, graph.Edges[3].InCycle, "d -> a is not part of the cycle")
+
+	nodes := make(map[string]ImportNode)
+	for _, node := range graph.Nodes {
+		nodes[node.Path] = node
+	}
+	assert.Equal(t, 2, nodes["example.com/ig/a"].FanIn)
+	assert.Equal(t, 1, nodes["example.com/ig/a"].FanOut)
+	assert.True(t, nodes["example.com/ig/a"].InCycle)
+	assert.False(t, nodes["example.com/ig/d"].InCycle)
+
+	t.Run("Patterns and external modules", func(t *testing.T) {
+		graph, err := BuildImportGraph(context.Background(), Options{Dir: root}, ImportGraphOptions{Patterns: []string{"./c", "example.com/ig/d"}, External: true})
+		require.NoError(t, err)
+		var edges []string
+		for _, edge := range graph.Edges {
+			edges = append(edges, edge.From+" -> "+edge.To)
+		}
+		assert.Equal(t, []string{
+			"example.com/ig/c -> example.com/ig/a",
+			"example.com/ig/c -> github.com/spf13/cobra",
+			"example.com/ig/d -> example.com/ig/a",
+		}, edges)
+		assert.Empty(t, graph.Cycles)
+		assert.True(t, graph.Nodes[len(graph.Nodes)-1].External)
+	})
+
+	t.Run("Formats", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, WriteImportGraph(&buf, graph, "dot"))
+		assert.Contains(t, buf.String(), `"example.com/ig/a" -> "example.com/ig/b" [color=red];`)
+
+		buf.Reset()
+		require.NoError(t, WriteImportGraph(&buf, graph, "mermaid"))
+		assert.Contains(t, buf.String(), "n0 --> n1\n")
+		assert.Contains(t, buf.String(), "linkStyle 0,1,2 stroke:red")
+
+		buf.Reset()
+		require.NoError(t, WriteImportGraph(&buf, graph, "text"))
+		assert.Contains(t, buf.String(), "example.com/ig/a -> example.com/ig/b -> example.com/ig/c -> example.com/ig/a")
+	})
+}
+
+func TestMatchesAnyPattern(t *testing.T) {
+	base := "/src/mod"
+	tests := []struct {
+		pattern string
+		dir     string
+		path    string
+		want    bool
+	}{
+		{"./...", "/src/mod/a/b", "example.com/mod/a/b", true},
+		{"./a/...", "/src/mod/a", "example.com/mod/a", true},
+		{"./a/...", "/src/mod/ab", "example.com/mod/ab", false},
+		{"./a", "/src/mod/a/b", "example.com/mod/a/b", false},
+		{"example.com/mod/a/...", "/src/mod/a/b", "example.com/mod/a/b", true},
+		{"example.com/mod/a", "/src/mod/a", "example.com/mod/a_test", true},
+		{"...", "/src/mod/x", "example.com/mod/x", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchesAnyPattern([]string{tt.pattern}, base, tt.dir, tt.path), tt.pattern+" "+tt.path)
+	}
+}
@@ -0,0 +1,244 @@
+package peekr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Package holds everything extracted from a package in a single pass over
+// its files. Every file is parsed exactly once and every extractor reads the
+// same syntax trees.
+type Package struct {
+	Name        string       `json:"name"`                  // Package name or import path that was requested
+	Dir         string       `json:"dir"`                   // Directory that was scanned
+	Files       []string     `json:"files"`                 // Source files that were read
+	Symbols     []Symbol     `json:"symbols"`               // Declarations found, sorted by file and position
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"` // Problems found while reading the package
+}
+
+// parsedFile is a source file parsed once and shared by every extractor.
+type parsedFile struct {
+	path        string
+	file        *ast.File // Possibly partial syntax tree; nil if unreadable
+	generated   bool
+	diagnostics []Diagnostic
+}
+
+// Load loads the package selected by opts.Dir and opts.Package and returns
+// everything peekr extracts from it. It is the entry point for using peekr as
+// a library: it never prints and never exits the process, and it writes
+// nothing to disk unless opts.Cache is set. Problems with individual files
+// are reported in Package.Diagnostics; the error is reserved for problems
+// that prevent loading altogether, such as a *PackageNotFoundError. Loading
+// stops as soon as ctx is cancelled or its deadline passes, and the error
+// then wraps ctx.Err(). Progress is reported to opts.Progress.
+func Load(ctx context.Context, opts Options) (*Package, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("Load(): Options.Dir is required")
+	}
+	if opts.Package == "" {
+		return nil, errors.New("Load(): Options.Package is required")
+	}
+	pkg, err := loadPackage(ctx, opts.Dir, opts.Package, opts)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("Load(): %w", ctx.Err())
+	}
+	return pkg, err
+}
+
+// LoadPackage discovers the files of a package, parses them in parallel and
+// runs every extractor over the results. pkgName may be a package name or an
+// import path. Parse errors are reported in Package.Diagnostics; a
+// *PackageNotFoundError is returned when no file declares the package.
+// With opts.Cache set, an unchanged package is read from the on-disk cache
+// instead of being parsed again.
+func LoadPackage(dir, pkgName string, opts Options) (*Package, error) {
+	return loadPackage(context.Background(), dir, pkgName, opts)
+}
+
+// loadPackage implements LoadPackage, stopping early when ctx is done.
+func loadPackage(ctx context.Context, dir, pkgName string, opts Options) (*Package, error) {
+	if opts.TypeCheck || opts.Usage {
+		opts = opts.typeCheckTarget()
+	}
+	target, err := resolveTarget(ctx, dir, pkgName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var key string
+	if opts.Cache && !opts.TypeCheck && !opts.Usage {
+		if key, err = cacheKey(dir, pkgName, opts, target); err == nil {
+			if pkg, ok := readCache(opts, key); ok {
+				return pkg, nil
+			}
+		}
+	}
+
+	fset := token.NewFileSet()
+	parsed, err := parseFiles(ctx, fset, target.files, opts, target.clauseFilter())
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := buildPackage(fset, dir, pkgName, opts, target, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TypeCheck {
+		if err := attachTypes(ctx, fset, pkg, opts, target.packageFiles(parsed)); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Usage {
+		if err := attachUsage(ctx, pkg, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// The cache is best effort: failing to write it never fails the load.
+	if key != "" {
+		writeCache(opts, key, target.files, pkg)
+	}
+	return pkg, nil
+}
+
+// buildPackage runs the extractors over the parsed files of a target and
+// assembles the Package, or a *PackageNotFoundError when no file declares it.
+func buildPackage(fset *token.FileSet, dir, pkgName string, opts Options, target scanTarget, parsed []*parsedFile) (*Package, error) {
+	diags := append([]Diagnostic(nil), target.diagnostics...)
+	var files []*parsedFile
+	var paths []string
+	found := false
+	for _, pf := range parsed {
+		// Problems in files of other packages are not problems of this one,
+		// but a file whose package clause is unreadable may belong to it.
+		if pf.file == nil || pf.file.Name == nil || pf.file.Name.Name == "" {
+			diags = append(diags, pf.diagnostics...)
+			continue
+		}
+		if !target.inPackage(pf.file) {
+			continue
+		}
+		diags = append(diags, pf.diagnostics...)
+		found = true
+		if target.generated.allows(pf.generated) {
+			files = append(files, pf)
+			paths = append(paths, pf.path)
+		}
+	}
+
+	diags = sortDiagnostics(diags)
+	if !found {
+		notFound := newPackageNotFoundError(dir, pkgName, opts)
+		notFound.Diagnostics = diags
+		return nil, notFound
+	}
+
+	return &Package{
+		Name:        pkgName,
+		Dir:         dir,
+		Files:       paths,
+		Symbols:     extractSymbols(fset, files),
+		Diagnostics: diags,
+	}, nil
+}
+
+// parseFiles parses paths with a bounded pool of workers. The results are in
+// the same order as paths, so output stays deterministic regardless of
+// scheduling. token.FileSet is safe for concurrent use. No new file is
+// started once ctx is done, and ctx.Err() is returned.
+//
+// When keep is not nil, each worker reads the package clause of its file
+// first and only parses the rest when keep accepts it, from the same bytes.
+// A rejected file is returned with nothing past its clause and without
+// diagnostics, since its problems belong to another package.
+func parseFiles(ctx context.Context, fset *token.FileSet, paths []string, opts Options, keep func(*ast.File) bool) ([]*parsedFile, error) {
+	results := make([]*parsedFile, len(paths))
+	workers := opts.workers()
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+	report := opts.progress()
+	var done int32
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f, diags := parseKept(fset, paths[i], keep)
+				pf := &parsedFile{path: paths[i], file: f, diagnostics: diags}
+				if f != nil {
+					pf.generated = ast.IsGenerated(f)
+				}
+				results[i] = pf
+
+				kind := ProgressParsed
+				if f == nil || len(diags) > 0 {
+					kind = ProgressFailed
+				}
+				report(ProgressEvent{Kind: kind, File: paths[i], Count: int(atomic.AddInt32(&done, 1)), Total: len(paths)})
+			}
+		}()
+	}
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// parseKept parses path for parseFiles, skipping everything past the
+// package clause when keep rejects it. Files whose clause cannot be read are
+// parsed in full: parsing reports why, and they may be kept after all.
+func parseKept(fset *token.FileSet, path string, keep func(*ast.File) bool) (*ast.File, []Diagnostic) {
+	if keep == nil {
+		return parseFile(fset, path, nil)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, diagnosticsFromError(path, err)
+	}
+	clause, _ := parser.ParseFile(fset, path, src, parser.PackageClauseOnly)
+	if clause != nil && clause.Name != nil && clause.Name.Name != "" && !keep(clause) {
+		return clause, nil
+	}
+	return parseFile(fset, path, src)
+}
+
+// workers returns the number of parser goroutines to use.
+func (opts Options) workers() int {
+	if opts.Workers > 0 {
+		return opts.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// errorDiagnostics returns the diagnostics carried by a load error, if any.
+func errorDiagnostics(err error) []Diagnostic {
+	var notFound *PackageNotFoundError
+	if errors.As(err, &notFound) {
+		return notFound.Diagnostics
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package peekr
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPackage(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{"go.mod": "module example.com/many\n"}
+	for i := 0; i < 40; i++ {
+		files[fmt.Sprintf("many/file%02d.go", i)] = fmt.Sprintf("package many\n\n// Func%02d is exported.\nfunc Func%02d() {}\n\ntype Type%02d struct{ A int }\n", i, i, i)
+	}
+	files["many/broken.go"] = "package many\n\nfunc Broken( {\n"
+	writeTree(t, root, files)
+
+	serial, err := LoadPackage(root, "many", Options{Workers: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 41, countSymbols(serial, FuncSymbol)) // The partial syntax tree of broken.go still yields Broken
+	assert.Equal(t, 40, countSymbols(serial, StructSymbol))
+	assert.Len(t, serial.Diagnostics, 1)
+
+	parallel, err := LoadPackage(root, "many", Options{Workers: 8})
+	require.NoError(t, err)
+	assert.Equal(t, serial, parallel)
+}
+
+func TestParseFilesByPackageClause(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":          "module example.com/names\n",
+		"a/a.go":          "package a\n\nfunc A() {}\n",
+		"a/a_test.go":     "package a_test\n\nfunc B() {}\n",
+		"b/b.go":          "package b\n\nfunc Broken( {\n",
+		"c/a.go":          "package a\n\nfunc C() {}\n",
+		"d/unreadable.go": "this is not go\n",
+	})
+
+	target, err := resolveTarget(context.Background(), root, "a", Options{Tests: true})
+	require.NoError(t, err)
+	parsed, err := parseFiles(context.Background(), token.NewFileSet(), target.files, Options{}, target.clauseFilter())
+	require.NoError(t, err)
+
+	// Files of other packages stop at their clause, so their syntax errors
+	// are neither parsed nor reported.
+	decls := make(map[string]int)
+	var diagnosed []string
+	for _, pf := range parsed {
+		rel, err := filepath.Rel(root, pf.path)
+		require.NoError(t, err)
+		rel = filepath.ToSlash(rel)
+		if pf.file != nil {
+			decls[rel] = len(pf.file.Decls)
+		}
+		if len(pf.diagnostics) > 0 {
+			diagnosed = append(diagnosed, rel)
+		}
+	}
+	assert.Equal(t, map[string]int{"a/a.go": 1, "a/a_test.go": 1, "b/b.go": 0, "c/a.go": 1, "d/unreadable.go": 0}, decls)
+	assert.Equal(t, []string{"d/unreadable.go"}, diagnosed)
+}
+
+// countSymbols returns the number of top-level symbols of the given kind.
+func countSymbols(pkg *Package, kind SymbolKind) int {
+	count := 0
+	for _, symbol := range pkg.Symbols {
+		if symbol.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":     "module example.com/lib\n",
+		"lib/a.go":   "package lib\n\n// A is exported.\nfunc A() {}\n",
+		"lib/b.go":   "package lib\n\ntype B struct{ X int }\n",
+		"other/c.go": "package other\n\nfunc C() {}\n",
+	})
+
+	pkg, err := Load(context.Background(), Options{Dir: root, Package: "lib"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "lib", "a.go"), filepath.Join(root, "lib", "b.go")}, pkg.Files)
+	assert.Equal(t, 1, countSymbols(pkg, FuncSymbol))
+	assert.Equal(t, 1, countSymbols(pkg, StructSymbol))
+
+	direct, err := LoadPackage(root, "lib", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, direct, pkg)
+
+	_, err = Load(context.Background(), Options{Dir: root, Package: "lbi"})
+	var notFound *PackageNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, []string{"lib"}, notFound.Suggestions)
+
+	_, err = Load(context.Background(), Options{Package: "lib"})
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Load(ctx, Options{Dir: root, Package: "lib"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,129 @@
+package peekr
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// renderMarkdown writes the package as a Markdown document: one section per
+// kind of symbol, one subsection per file, and a table for struct fields.
+func renderMarkdown(w io.Writer, pkg *Package, opts RenderOptions) error {
+	mw := &markdownWriter{w: w, dir: pkg.Dir}
+	mw.printf("# Package `%s`\n", pkg.Name)
+
+	symbols := opts.symbols(pkg)
+	if opts.Functions {
+		sections := make(map[TestKind][]Symbol)
+		for _, symbol := range symbols {
+			if symbol.Kind == FuncSymbol {
+				sections[symbol.TestKind] = append(sections[symbol.TestKind], symbol)
+			}
+		}
+		mw.section("Functions", sections[""])
+		for _, section := range testSections {
+			if len(sections[section.Kind]) > 0 {
+				mw.section(section.Title, sections[section.Kind])
+			}
+		}
+	}
+	if opts.Structs {
+		var structs []Symbol
+		for _, symbol := range symbols {
+			if symbol.Kind == StructSymbol {
+				structs = append(structs, symbol)
+			}
+		}
+		mw.section("Structs", structs)
+	}
+
+	if len(pkg.Diagnostics) > 0 {
+		mw.printf("\n## Diagnostics\n\n")
+		for _, d := range sortDiagnostics(append([]Diagnostic(nil), pkg.Diagnostics...)) {
+			mw.printf("- `%s`\n", d.String())
+		}
+	}
+	return mw.err
+}
+
+// markdownWriter remembers the first write error so rendering code does not
+// have to check every write.
+type markdownWriter struct {
+	w   io.Writer
+	dir string
+	err error
+}
+
+// printf writes a formatted string unless an earlier write failed.
+func (mw *markdownWriter) printf(format string, args ...interface{}) {
+	if mw.err == nil {
+		_, mw.err = fmt.Fprintf(mw.w, format, args...)
+	}
+}
+
+// section writes a heading followed by the symbols grouped by file, in the
+// order they are given.
+func (mw *markdownWriter) section(title string, symbols []Symbol) {
+	mw.printf("\n## %s\n", title)
+	if len(symbols) == 0 {
+		mw.printf("\nNone.\n")
+		return
+	}
+
+	file := ""
+	for _, symbol := range symbols {
+		if symbol.File != file {
+			file = symbol.File
+			mw.printf("\n### %s\n", mw.relative(file))
+		}
+		mw.symbol(symbol)
+	}
+}
+
+// symbol writes the heading, declaration and doc comment of a symbol, plus a
+// field table for structs.
+func (mw *markdownWriter) symbol(symbol Symbol) {
+	mw.printf("\n#### %s\n\n", symbol.Name)
+	switch symbol.Kind {
+	case FuncSymbol:
+		receiver := ""
+		if symbol.Receiver != "" {
+			receiver = "(" + symbol.Receiver + ") "
+		}
+		mw.printf("```go\nfunc %s%s%s\n```\n", receiver, symbol.Name, symbol.ResolvedSignature())
+	case StructSymbol:
+		mw.printf("```go\ntype %s struct\n```\n", symbol.Name)
+	}
+	if symbol.Generated {
+		mw.printf("\n_generated_\n")
+	}
+	if doc := strings.TrimSpace(symbol.Doc); doc != "" {
+		mw.printf("\n%s\n", doc)
+	}
+	if label := referencesLabel(symbol); label != "" {
+		mw.printf("\n_%s_\n", strings.Trim(label, "[]"))
+	}
+
+	if len(symbol.Children) > 0 {
+		mw.printf("\n| Field | Type | Description |\n| --- | --- | --- |\n")
+		for _, field := range symbol.Children {
+			mw.printf("| `%s` | `%s` | %s |\n", field.Name, field.ResolvedSignature(), markdownCell(field.Doc))
+		}
+	}
+}
+
+// relative returns path relative to the scanned directory when possible.
+func (mw *markdownWriter) relative(path string) string {
+	if rel, err := filepath.Rel(mw.dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// markdownCell flattens text onto one line and escapes pipes so it fits in a
+// table cell.
+func markdownCell(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.ReplaceAll(text, "|", `\|`)
+}
@@ -0,0 +1,196 @@
+package peekr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// MatrixRow records on which platforms a single exported symbol exists.
+type MatrixRow struct {
+	Kind      string          `json:"kind"`                // "func" or "struct"
+	Name      string          `json:"name"`                // Name of the symbol
+	Receiver  string          `json:"receiver,omitempty"`  // Receiver type of a method, e.g. "*Options"
+	Platforms map[string]bool `json:"platforms"`           // Platforms the symbol exists on, keyed by Platform.String()
+	Generated bool            `json:"generated,omitempty"` // Whether the symbol was declared in a generated file
+}
+
+// PlatformMatrix shows which exported symbols of a package exist for which
+// build targets.
+type PlatformMatrix struct {
+	Platforms   []Platform   `json:"platforms"`
+	Rows        []MatrixRow  `json:"rows"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// MatrixFormats lists the formats WritePlatformMatrix supports.
+var MatrixFormats = []string{"text", "json"}
+
+// Universal reports whether the symbol exists on every platform in the matrix.
+func (r MatrixRow) Universal(platforms []Platform) bool {
+	for _, p := range platforms {
+		if !r.Platforms[p.String()] {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildPlatformMatrix extracts the package once per platform, applying the
+// build constraints of each target, and records where every exported function
+// and struct is defined. The GOOS and GOARCH in opts are ignored; Tags apply
+// to every platform. Building stops with ctx.Err() once ctx is done.
+func BuildPlatformMatrix(ctx context.Context, dir, pkgName string, opts Options, platforms []Platform) (*PlatformMatrix, error) {
+	if len(platforms) == 0 {
+		platforms = DefaultPlatforms
+	}
+
+	rows := make(map[string]*MatrixRow)
+	var diags []Diagnostic
+	var notFound *PackageNotFoundError
+	missing := 0
+	// Rows are keyed by symbol ID, so methods of different types that share
+	// a name get rows of their own.
+	record := func(symbol Symbol, p Platform) {
+		row, ok := rows[symbol.ID()]
+		if !ok {
+			row = &MatrixRow{Kind: string(symbol.Kind), Name: symbol.Name, Receiver: symbol.Receiver, Platforms: make(map[string]bool)}
+			rows[symbol.ID()] = row
+		}
+		row.Platforms[p.String()] = true
+		row.Generated = row.Generated || symbol.Generated
+	}
+
+	for _, p := range platforms {
+		targetOpts := opts
+		targetOpts.GOOS = p.GOOS
+		targetOpts.GOARCH = p.GOARCH
+
+		pkg, err := loadPackage(ctx, dir, pkgName, targetOpts)
+		if errors.As(err, &notFound) {
+			// The package may only exist on some of the platforms.
+			diags = append(diags, notFound.Diagnostics...)
+			missing++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		diags = append(diags, pkg.Diagnostics...)
+
+		for _, symbol := range pkg.Symbols {
+			record(symbol, p)
+		}
+	}
+
+	if missing == len(platforms) {
+		return nil, notFound
+	}
+
+	matrix := &PlatformMatrix{Platforms: platforms, Diagnostics: sortDiagnostics(diags)}
+	for _, row := range rows {
+		matrix.Rows = append(matrix.Rows, *row)
+	}
+	sort.Slice(matrix.Rows, func(i, j int) bool {
+		a, b := matrix.Rows[i], matrix.Rows[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Receiver < b.Receiver
+	})
+	return matrix, nil
+}
+
+// ListPlatformMatrix builds and prints the platform matrix of a package.
+// Diagnostics are returned for the caller to report with PrintDiagnostics;
+// load errors are returned for the caller to report with PrintError.
+func ListPlatformMatrix(dir, pkgName string, opts Options, platforms []Platform) ([]Diagnostic, error) {
+	matrix, err := BuildPlatformMatrix(context.Background(), dir, pkgName, opts, platforms)
+	if err != nil {
+		return errorDiagnostics(err), err
+	}
+
+	PrintPlatformMatrix(os.Stdout, pkgName, matrix)
+	return matrix.Diagnostics, nil
+}
+
+// WritePlatformMatrix writes matrix to w in one of MatrixFormats: the
+// color-coded table of PrintPlatformMatrix, or an indented JSON document.
+func WritePlatformMatrix(w io.Writer, pkgName string, matrix *PlatformMatrix, format string) error {
+	switch format {
+	case "text":
+		PrintPlatformMatrix(w, pkgName, matrix)
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(matrix)
+	}
+	return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(MatrixFormats, ", "))
+}
+
+// PrintPlatformMatrix writes a color-coded table of the package's exported
+// symbols against the matrix platforms to w. Symbols that exist everywhere
+// are printed in green, platform-specific ones in yellow.
+func PrintPlatformMatrix(w io.Writer, pkgName string, matrix *PlatformMatrix) {
+	if len(matrix.Rows) == 0 {
+		header := fmt.Sprintf("\nNo symbols in the %s package:", fmt.Sprintf("'%s'", pkgName))
+		helpers.FprintColor(w, header, helpers.Error)
+		return
+	}
+
+	label := func(row MatrixRow) string {
+		name := row.Kind + " " + row.Name
+		if row.Receiver != "" {
+			name = row.Kind + " (" + row.Receiver + ")." + row.Name
+		}
+		if row.Generated {
+			return name + " [generated]"
+		}
+		return name
+	}
+
+	nameWidth := 0
+	for _, row := range matrix.Rows {
+		if width := len(label(row)); width > nameWidth {
+			nameWidth = width
+		}
+	}
+
+	header := fmt.Sprintf("\nPlatform matrix for the %s package:\n", fmt.Sprintf("'%s'", pkgName))
+	helpers.FprintColor(w, header, helpers.Info)
+
+	columns := []string{fmt.Sprintf("  %-*s", nameWidth, "")}
+	for _, p := range matrix.Platforms {
+		columns = append(columns, p.String())
+	}
+	helpers.FprintColor(w, strings.Join(columns, "  "), helpers.Cyan)
+
+	for _, row := range matrix.Rows {
+		cells := []string{fmt.Sprintf("  %-*s", nameWidth, label(row))}
+		for _, p := range matrix.Platforms {
+			mark := "-"
+			if row.Platforms[p.String()] {
+				mark = "x"
+			}
+			cells = append(cells, fmt.Sprintf("%-*s", len(p.String()), mark))
+		}
+
+		level := helpers.Debug
+		if !row.Universal(matrix.Platforms) {
+			level = helpers.Warn
+		}
+		helpers.FprintColor(w, strings.Join(cells, "  "), level)
+	}
+	fmt.Fprintln(w)
+}
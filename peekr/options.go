@@ -0,0 +1,104 @@
+package peekr
+
+// Options controls how peekr discovers and parses the source files of a package.
+// The zero value scans the given directory, follows any go.work file found there,
+// and skips vendored code.
+type Options struct {
+	// Dir and Package select the package loaded by Load: the directory to
+	// scan and the package name or import path to look for. Functions that
+	// take a directory and package name as arguments ignore them.
+	Dir     string
+	Package string
+
+	// Vendor includes vendored packages, honoring vendor/modules.txt the same
+	// way the go command does with -mod=vendor.
+	Vendor bool
+
+	// NoWorkspace ignores go.work files and scans only the given directory.
+	NoWorkspace bool
+
+	// GOOS, GOARCH and Tags select a build target. When any of them is set,
+	// files excluded by //go:build lines or _GOOS/_GOARCH file name suffixes
+	// are skipped; empty GOOS and GOARCH default to the host platform. When
+	// none is set, every file is read regardless of its build constraints.
+	GOOS   string
+	GOARCH string
+	Tags   []string
+
+	// Tests includes _test.go files and the external pkg_test package. Test
+	// functions, examples, benchmarks, fuzz targets and test helpers are
+	// reported with their TestKind set.
+	Tests bool
+
+	// Generated selects whether files carrying the standard
+	// "// Code generated ... DO NOT EDIT." header are included.
+	Generated GeneratedFilter
+
+	// GitIgnore also honors .gitignore files in addition to .peekrignore.
+	GitIgnore bool
+
+	// NoIgnore disables .peekrignore and .gitignore files as well as the
+	// built-in rules that skip dot, underscore, testdata and node_modules
+	// directories. Include and Exclude still apply.
+	NoIgnore bool
+
+	// Include and Exclude are gitignore-style globs matched against paths
+	// relative to the directory being walked. When Include is not empty,
+	// only files matching one of its patterns are read.
+	Include []string
+	Exclude []string
+
+	// Workers bounds how many files are parsed concurrently. Zero uses one
+	// worker per CPU.
+	Workers int
+
+	// Cache reuses extraction results stored on disk when none of the
+	// package's files changed, and stores new results there. Files whose
+	// size and modification time are unchanged are trusted; otherwise their
+	// content hash decides. CacheDir overrides the default cache location.
+	Cache    bool
+	CacheDir string
+
+	// TypeCheck type-checks the package from source with go/types and
+	// records the resolved type, underlying type and package-qualified name
+	// of every symbol. Aliases are resolved to the types they stand for.
+	// Imports within the workspace are checked from their sources; others,
+	// including the standard library, are located with go/build and checked
+	// from source too. A package and its imports are checked for a single
+	// build target, the host platform unless GOOS or GOARCH is set.
+	// Results are never cached.
+	TypeCheck bool
+
+	// Usage counts the references to every symbol, and to every field of
+	// its structs, from all packages of the workspace. Like TypeCheck, it
+	// type-checks the packages for a single build target, and its results
+	// are never cached.
+	Usage bool
+
+	// Progress, when not nil, is called as files are discovered and parsed.
+	// Calls never overlap, but may come from different goroutines. Progress
+	// is not called for packages read from the cache.
+	Progress func(ProgressEvent)
+}
+
+// GeneratedFilter selects how generated code is treated during a scan.
+type GeneratedFilter int
+
+// Define constants for the generated code filters.
+const (
+	IncludeGenerated GeneratedFilter = iota // Include generated and hand-written code
+	ExcludeGenerated                        // Skip generated files
+	OnlyGenerated                           // Skip hand-written files
+)
+
+// allows reports whether a file with the given generated state passes the filter.
+func (g GeneratedFilter) allows(generated bool) bool {
+	switch g {
+	case ExcludeGenerated:
+		return !generated
+	case OnlyGenerated:
+		return generated
+	default:
+		return true
+	}
+}
@@ -1,10 +1,9 @@
 package peekr
 
 import (
+	"errors"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -16,309 +15,343 @@ import (
 
 var Logger = config.GetLogger()
 
-// Info is a common interface for items that can be printed by commonOutput.
-type Info interface {
-	GetFileName() string
-}
-
-// Implement the interface for FunctionInfo.
-func (fi FunctionInfo) GetFileName() string {
-	return fi.FileName
-}
-
-// Implement the interface for StructInfo (assuming similar fields and formatting needs).
-func (si StructInfo) GetFileName() string {
-	return si.Name // Assuming StructInfo has a Name field for the file name
-}
-
 // FunctionInfo holds metadata about a function within a Go source file.
 // It includes the file name, function signature, associated comments,
-// parameter list, and return types.
+// parameter list, return types and package clause. Functions found in
+// _test.go files also record their TestKind, and functions in generated
+// files are flagged as Generated.
+//
+// Deprecated: use Load and the FuncSymbol entries of Package.Symbols.
 type FunctionInfo struct {
-	FileName string
-	Function string
-	Comments string
-	Params   string
-	Returns  string
+	FileName  string
+	Function  string
+	Comments  string
+	Params    string
+	Returns   string
+	Package   string
+	TestKind  TestKind
+	Generated bool
 }
 
 // StructInfo holds metadata about a struct type within a Go source file.
-// It includes the struct name, slice of its fields, and associated comments.
+// It includes the struct name, the name of its file without the extension,
+// slice of its fields, associated comments, and whether it was declared in
+// a generated file.
+//
+// Deprecated: use Load and the StructSymbol entries of Package.Symbols.
 type StructInfo struct {
-	Name     string
-	FileName string
-	Fields   []FieldInfo
-	Comment  string
+	Name      string
+	FileName  string
+	Fields    []FieldInfo
+	Comment   string
+	Generated bool
 }
 
 // FieldInfo holds metadata about a field within a struct.
 // It includes the field name, field type, and associated comments.
+//
+// Deprecated: use the Children of a StructSymbol.
 type FieldInfo struct {
 	Name    string
 	Type    string
 	Comment string
 }
 
-// commonOutput handles the shared output logic.
-func commonOutput(pkgName string, infoMap map[string][]Info, infoType string) {
+// generatedMarker is printed above symbols that come from generated files.
+const generatedMarker = "  [generated]"
+
+// changedMarker is printed above symbols highlighted as changed in watch mode.
+const changedMarker = "  [changed]"
+
+// referencesLabel describes the reference count of a symbol loaded with
+// Options.Usage, e.g. "[3 references]". It is empty when references were
+// not counted.
+func referencesLabel(symbol Symbol) string {
+	switch {
+	case symbol.References == nil:
+		return ""
+	case *symbol.References == 1:
+		return "[1 reference]"
+	default:
+		return fmt.Sprintf("[%d references]", *symbol.References)
+	}
+}
+
+// commonOutput handles the shared output logic for symbols grouped by file.
+// Symbols whose ID is in changed are highlighted; changed may be nil.
+func commonOutput(w io.Writer, pkgName string, groups map[string][]Symbol, title string, changed map[string]bool) {
 	var groupNames []string
-	for groupName := range infoMap {
+	for groupName := range groups {
 		groupNames = append(groupNames, groupName)
 	}
 	sort.Strings(groupNames)
 
-	if len(groupNames) > 0 {
-		header := fmt.Sprintf("\n%s in the %s package:", infoType, fmt.Sprintf("'%s'", pkgName))
-		helpers.TerminalColor(header, helpers.Info)
-		for _, groupName := range groupNames {
-			infos := infoMap[groupName]
-			helpers.TerminalColor("\nFile: "+groupName+"\n", helpers.Cyan)
-			for _, info := range infos {
-				switch v := info.(type) {
-				case FunctionInfo:
-					helpers.TerminalColor(v.Comments, helpers.Cyan)
-					signature := fmt.Sprintf("  %s(%s) %s", v.Function, v.Params, v.Returns)
-					helpers.TerminalColor(signature, helpers.Debug)
-				case StructInfo:
-					helpers.TerminalColor(v.Comment, helpers.Cyan)
-
-					maxLength := 0
-					for _, field := range v.Fields {
-						if len(field.Name) > maxLength {
-							maxLength = len(field.Name)
-						}
-					}
-
-					for _, field := range v.Fields {
-						formattedField := fmt.Sprintf("  %-*s  %s", maxLength+2, field.Name, field.Type)
-						helpers.TerminalColor(formattedField, helpers.Debug)
-					}
-
-				default:
-					fmt.Println("Unknown type")
+	if len(groupNames) == 0 {
+		header := fmt.Sprintf("\nNo %s in the %s package:", title, fmt.Sprintf("'%s'", pkgName))
+		helpers.FprintColor(w, header, helpers.Error)
+		return
+	}
+
+	header := fmt.Sprintf("\n%s in the %s package:", title, fmt.Sprintf("'%s'", pkgName))
+	helpers.FprintColor(w, header, helpers.Info)
+	for _, groupName := range groupNames {
+		helpers.FprintColor(w, "\nFile: "+groupName+"\n", helpers.Cyan)
+		for _, symbol := range groups[groupName] {
+			level := helpers.Debug
+			if changed[symbol.ID()] {
+				level = helpers.Alert
+			}
+
+			helpers.FprintColor(w, Commentify(symbol.Doc), helpers.Cyan)
+			if symbol.Generated {
+				helpers.FprintColor(w, generatedMarker, helpers.Notice)
+			}
+			if level == helpers.Alert {
+				helpers.FprintColor(w, changedMarker, helpers.Alert)
+			}
+			if label := referencesLabel(symbol); label != "" {
+				helpers.FprintColor(w, "  "+label, helpers.LightPurple)
+			}
+
+			if symbol.Kind == FuncSymbol {
+				helpers.FprintColor(w, "  "+symbol.Name+symbol.ResolvedSignature(), level)
+				fmt.Fprintln(w)
+				continue
+			}
+
+			maxLength := 0
+			for _, field := range symbol.Children {
+				if len(field.Name) > maxLength {
+					maxLength = len(field.Name)
+				}
+			}
+			for _, field := range symbol.Children {
+				formattedField := fmt.Sprintf("  %-*s  %s", maxLength+2, field.Name, field.ResolvedSignature())
+				if label := referencesLabel(field); label != "" {
+					formattedField += "  " + label
 				}
-				fmt.Println()
+				helpers.FprintColor(w, formattedField, level)
 			}
+			fmt.Fprintln(w)
 		}
-	} else {
-		header := fmt.Sprintf("\nNo %s in the %s package:", infoType, fmt.Sprintf("'%s'", pkgName))
-		helpers.TerminalColor(header, helpers.Error)
 	}
 }
 
-// ListPackageFunctions prints a color-coded list of functions from the specified package.
-// It retrieves function metadata using PackageFunctions and formats the output.
-func ListPackageFunctions(dir, pkgName string) {
-	functionMap, err := PackageFunctions(dir, pkgName)
-	if err != nil {
-		Logger.Error(err.Error())
-		os.Exit(1)
+// PrintError reports a scan error along with any diagnostics it carries. A
+// missing package is printed with suggestions; anything else is logged.
+func PrintError(err error) {
+	PrintDiagnostics(errorDiagnostics(err))
+
+	var notFound *PackageNotFoundError
+	if errors.As(err, &notFound) {
+		printPackageNotFound(notFound)
+		return
 	}
+	Logger.Error(err.Error())
+}
 
-	infoMap := make(map[string][]Info)
-	for k, v := range functionMap {
-		var infos []Info
-		for _, fi := range v {
-			infos = append(infos, fi)
-		}
-		infoMap[k] = infos
+// ListPackage prints the functions and/or structs of a package from a single
+// load, so every file is parsed only once. Diagnostics are returned for the
+// caller to report with PrintDiagnostics; load errors are returned for the
+// caller to report with PrintError.
+func ListPackage(dir, pkgName string, opts Options, functions, structs bool) ([]Diagnostic, error) {
+	pkg, err := LoadPackage(dir, pkgName, opts)
+	if err != nil {
+		return errorDiagnostics(err), err
 	}
 
-	commonOutput(pkgName, infoMap, "Functions")
+	PrintPackage(pkg, functions, structs, nil)
+	return pkg.Diagnostics, nil
 }
 
-// ListPackageStructs prints a color-coded list of structs from the specified package.
-// It retrieves struct metadata using PackageStructs and formats the output.
-func ListPackageStructs(dir, pkgName string) {
-	structsMap, err := PackageStructs(dir, pkgName)
-	if err != nil {
-		Logger.Error(err.Error())
-		os.Exit(1)
+// PrintPackage prints the functions and/or structs of an already loaded
+// package. When diff is not nil, changed symbols are highlighted and removed
+// ones are listed at the end.
+func PrintPackage(pkg *Package, functions, structs bool, diff *SymbolDiff) {
+	writePackage(os.Stdout, pkg, RenderOptions{Functions: functions, Structs: structs, Diff: diff})
+}
+
+// writePackage writes the color-coded listing of a package to w.
+func writePackage(w io.Writer, pkg *Package, opts RenderOptions) {
+	var changed map[string]bool
+	if opts.Diff != nil {
+		changed = opts.Diff.Changed
 	}
 
-	infoMap := make(map[string][]Info)
-	for k, v := range structsMap {
-		var infos []Info
-		for _, si := range v {
-			infos = append(infos, si)
+	if opts.Functions {
+		printFunctions(w, pkg.Name, pkg.Symbols, changed)
+	}
+	if opts.Structs {
+		printStructs(w, pkg.Name, pkg.Symbols, changed)
+	}
+	if opts.Diff != nil && len(opts.Diff.Removed) > 0 {
+		helpers.FprintColor(w, "\nRemoved since the last change:\n", helpers.Alert)
+		for _, removed := range opts.Diff.Removed {
+			helpers.FprintColor(w, "  "+removed, helpers.Alert)
 		}
-		infoMap[k] = infos
+		fmt.Fprintln(w)
 	}
+}
 
-	commonOutput(pkgName, infoMap, "Structs")
+// ListPackageFunctions prints a color-coded list of functions from the specified package.
+// When opts.Tests is set, test helpers, tests, examples, benchmarks and fuzz
+// targets are printed in sections of their own after the package functions.
+// Files that could not be parsed are returned as diagnostics for the caller
+// to report with PrintDiagnostics.
+func ListPackageFunctions(dir, pkgName string, opts Options) ([]Diagnostic, error) {
+	return ListPackage(dir, pkgName, opts, true, false)
 }
 
-// PackageFunctions retrieves a map of FunctionInfo slices indexed by file path.
-// It scans the specified package directory for Go files and extracts metadata
-// for each exported function.
-func PackageFunctions(dir, pkgName string) (map[string][]FunctionInfo, error) {
-	fset := token.NewFileSet()                 // Create a new file set for parsing.
-	funcMap := make(map[string][]FunctionInfo) // Initialize a map to store function information.
-
-	// Walk the directory tree recursively to find Go files.
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// printFunctions prints the functions among symbols grouped by file, sorted
+// by name, with test functions split into their own sections.
+func printFunctions(w io.Writer, pkgName string, symbols []Symbol, changed map[string]bool) {
+	sections := make(map[TestKind]map[string][]Symbol)
+	for _, symbol := range symbols {
+		if symbol.Kind != FuncSymbol {
+			continue
 		}
-
-		// Skip directories and test files.
-		if info.IsDir() || strings.HasSuffix(info.Name(), "_test.go") {
-			return nil
+		groupName := symbol.File
+		if symbol.TestKind != "" && strings.HasSuffix(symbol.Package, "_test") {
+			groupName = fmt.Sprintf("%s (package %s)", symbol.File, symbol.Package)
+		}
+		if sections[symbol.TestKind] == nil {
+			sections[symbol.TestKind] = make(map[string][]Symbol)
 		}
+		sections[symbol.TestKind][groupName] = append(sections[symbol.TestKind][groupName], symbol)
+	}
+	for _, groups := range sections {
+		for _, functions := range groups {
+			sort.SliceStable(functions, func(i, j int) bool {
+				return functions[i].Name < functions[j].Name
+			})
+		}
+	}
 
-		// Parse only Go files that match the specified package name.
-		if strings.HasSuffix(info.Name(), ".go") {
-			// Parse the Go source file
-			f, parseErr := parser.ParseFile(fset, path, nil, parser.ParseComments)
-			if parseErr != nil {
-				return parseErr
-			}
+	commonOutput(w, pkgName, sections[""], "Functions", changed)
+	for _, section := range testSections {
+		if len(sections[section.Kind]) > 0 {
+			commonOutput(w, pkgName, sections[section.Kind], section.Title, changed)
+		}
+	}
+}
 
-			// Check if the file's package name matches the desired package.
-			if f.Name.Name != pkgName {
-				return nil
-			}
+// ListPackageStructs prints a color-coded list of structs from the specified package.
+// Files that could not be parsed are returned as diagnostics for the caller
+// to report with PrintDiagnostics.
+func ListPackageStructs(dir, pkgName string, opts Options) ([]Diagnostic, error) {
+	return ListPackage(dir, pkgName, opts, false, true)
+}
 
-			// Use the file name without the extension for grouping
-			groupName := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
-
-			// Process declarations within the file.
-			for _, decl := range f.Decls {
-				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.IsExported() {
-					// Extract comments, parameters, and return types for exported functions.
-					var comments string
-					if fn.Doc != nil {
-						comments = Commentify(fn.Doc.Text())
-					}
-
-					// Extract the function's parameters and return types
-					params := ExtractFuncParams(fn.Type.Params)
-					returns := ExtractFuncResults(fn.Type.Results)
-
-					// Store the function information in the map.
-					funcInfo := FunctionInfo{
-						FileName: groupName,
-						Function: fn.Name.Name,
-						Comments: comments,
-						Params:   params,
-						Returns:  returns,
-					}
-					funcMap[path] = append(funcMap[path], funcInfo)
-				}
-			}
+// printStructs prints the structs among symbols grouped by file, in
+// declaration order.
+func printStructs(w io.Writer, pkgName string, symbols []Symbol, changed map[string]bool) {
+	groups := make(map[string][]Symbol)
+	for _, symbol := range symbols {
+		if symbol.Kind == StructSymbol {
+			groups[symbol.File] = append(groups[symbol.File], symbol)
 		}
+	}
 
-		return nil
-	})
+	commonOutput(w, pkgName, groups, "Structs", changed)
+}
 
+// PackageFunctions retrieves a map of FunctionInfo slices indexed by file path.
+// It scans the specified package directory for Go files and extracts metadata
+// for each exported function. pkgName may be a package name or an import path.
+// When opts.Tests is set, every function in _test.go files is included as well,
+// exported or not, since test helpers are rarely exported.
+//
+// Files with syntax errors do not stop the scan: whatever could be parsed is
+// still extracted, and the errors are returned as diagnostics. The error
+// result is reserved for problems that prevent scanning altogether, and is a
+// *PackageNotFoundError when no file declares the requested package.
+//
+// Deprecated: use Load, which returns every kind of symbol from a single scan.
+func PackageFunctions(dir, pkgName string, opts Options) (map[string][]FunctionInfo, []Diagnostic, error) {
+	pkg, err := LoadPackage(dir, pkgName, opts)
 	if err != nil {
-		if strings.Contains(err.Error(), "EOF") {
-			return funcMap, nil
-		} else {
-			return nil, err
-		}
+		return nil, errorDiagnostics(err), err
+	}
 
+	funcMap := make(map[string][]FunctionInfo)
+	for _, symbol := range pkg.Symbols {
+		if symbol.Kind != FuncSymbol {
+			continue
+		}
+		params, returns := splitSignature(symbol.Signature)
+		funcMap[symbol.File] = append(funcMap[symbol.File], FunctionInfo{
+			FileName:  baseName(symbol.File),
+			Function:  symbol.Name,
+			Comments:  Commentify(symbol.Doc),
+			Params:    params,
+			Returns:   returns,
+			Package:   symbol.Package,
+			TestKind:  symbol.TestKind,
+			Generated: symbol.Generated,
+		})
 	}
 
-	// Sort the functions within each group alphabetically
+	// Sort the functions within each file alphabetically
 	for _, functions := range funcMap {
-		sort.Slice(functions, func(i, j int) bool {
+		sort.SliceStable(functions, func(i, j int) bool {
 			return functions[i].Function < functions[j].Function
 		})
 	}
-
-	return funcMap, nil
+	return funcMap, pkg.Diagnostics, nil
 }
 
 // PackageStructs retrieves a map of StructInfo slices indexed by file path.
 // It scans the specified package directory for Go files and extracts metadata
-// for each exported struct.
-func PackageStructs(dir, pkgName string) (map[string][]StructInfo, error) {
-	fset := token.NewFileSet()                  // Create a new file set for parsing.
-	structsMap := make(map[string][]StructInfo) // Initialize a map to store struct information.
-
-	// Walk through the directory tree to find Go source files.
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Ignore directories, non-Go files, and test files.
-		if info.IsDir() || !strings.HasSuffix(info.Name(), ".go") || strings.HasSuffix(info.Name(), "_test.go") {
-			return nil
-		}
+// for each exported struct. pkgName may be a package name or an import path.
+// Like PackageFunctions, it reports unparsable files as diagnostics and keeps going.
+//
+// Deprecated: use Load, which returns every kind of symbol from a single scan.
+func PackageStructs(dir, pkgName string, opts Options) (map[string][]StructInfo, []Diagnostic, error) {
+	pkg, err := LoadPackage(dir, pkgName, opts)
+	if err != nil {
+		return nil, errorDiagnostics(err), err
+	}
 
-		// Parse the Go source file.
-		f, parseErr := parser.ParseFile(fset, path, nil, parser.ParseComments)
-		if parseErr != nil {
-			return parseErr
+	structsMap := make(map[string][]StructInfo)
+	for _, symbol := range pkg.Symbols {
+		if symbol.Kind != StructSymbol {
+			continue
 		}
-
-		// Ensure the file belongs to the specified package.
-		if f.Name.Name != pkgName {
-			return nil
+		fields := make([]FieldInfo, 0, len(symbol.Children))
+		for _, field := range symbol.Children {
+			fields = append(fields, FieldInfo{Name: field.Name, Type: field.Signature, Comment: field.Doc})
 		}
+		structsMap[symbol.File] = append(structsMap[symbol.File], StructInfo{
+			Name:      symbol.Name,
+			FileName:  baseName(symbol.File),
+			Fields:    fields,
+			Comment:   Commentify(symbol.Doc),
+			Generated: symbol.Generated,
+		})
+	}
+	return structsMap, pkg.Diagnostics, nil
+}
 
-		// Iterate over all declarations within the file.
-		for _, decl := range f.Decls {
-			genDecl, ok := decl.(*ast.GenDecl)
-			if !ok || genDecl.Tok != token.TYPE {
-				continue
-			}
-
-			// Process type specifications within the declaration.
-			for _, spec := range genDecl.Specs {
-				typeSpec, ok := spec.(*ast.TypeSpec)
-				if !ok {
-					continue
-				}
-
-				structType, ok := typeSpec.Type.(*ast.StructType)
-				if !ok || !typeSpec.Name.IsExported() {
-					continue
-				}
-
-				// Retrieve documentation comments for the struct, if any.
-				var structComment string
-				if genDecl.Doc != nil {
-					structComment = Commentify(genDecl.Doc.Text())
-				}
-
-				// Collect information about fields within the struct.
-				structFields := make([]FieldInfo, 0)
-				for _, field := range structType.Fields.List {
-					fieldType := ExprToString(field.Type)
-					var fieldComment string
-					if field.Doc != nil {
-						fieldComment = field.Doc.Text()
-					}
-
-					// Add each field to the struct's field list.
-					for _, fieldName := range field.Names {
-						structFields = append(structFields, FieldInfo{
-							Name:    fieldName.Name,
-							Type:    fieldType,
-							Comment: fieldComment,
-						})
-					}
-				}
+// baseName returns the name of a file without its directory or extension.
+func baseName(path string) string {
+	fileName := filepath.Base(path)
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
+}
 
-				// Store the struct information in the map.
-				structInfo := StructInfo{
-					Name:    typeSpec.Name.Name,
-					Fields:  structFields,
-					Comment: structComment,
-				}
-				structsMap[path] = append(structsMap[path], structInfo)
+// splitSignature splits a "(params) results" function signature into the
+// parameter list and the results.
+func splitSignature(signature string) (string, string) {
+	depth := 0
+	for i, r := range signature {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return signature[1:i], strings.TrimSpace(signature[i+1:])
 			}
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
 	}
-
-	return structsMap, nil
+	return signature, ""
 }
@@ -0,0 +1,156 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PluginPrefix is the prefix of the executables peekr runs as plugins: an
+// executable named peekr-<name> on PATH becomes the 'peekr <name>' command.
+const PluginPrefix = "peekr-"
+
+// PluginProtocolVersion is the version of the JSON document plugins receive
+// on stdin. It changes only when the document changes incompatibly, so
+// plugins should refuse versions they do not know.
+const PluginProtocolVersion = 1
+
+// PluginProtocolEnv names the environment variable that tells a plugin which
+// protocol version it is being spoken to with, before it reads stdin.
+const PluginProtocolEnv = "PEEKR_PLUGIN_PROTOCOL"
+
+// Plugin is an external peekr-<name> executable found on PATH.
+type Plugin struct {
+	Name string // Command name, without the peekr- prefix
+	Path string // Absolute path of the executable
+}
+
+// PluginRequest is the JSON document written to a plugin's stdin. The
+// package is encoded exactly as by the json format.
+type PluginRequest struct {
+	Protocol int      `json:"protocol"`     // Always PluginProtocolVersion
+	Version  string   `json:"peekrVersion"` // Version of the peekr that ran the plugin
+	Args     []string `json:"args"`         // Arguments given after the plugin name
+	Package  *Package `json:"package"`      // The loaded package
+}
+
+// PluginError is returned by RunPlugin when the plugin exits with a non-zero
+// status, so the caller can exit with the same status.
+type PluginError struct {
+	Plugin   string
+	ExitCode int
+}
+
+// Error implements the error interface.
+func (e *PluginError) Error() string {
+	return fmt.Sprintf("plugin %s exited with status %d", e.Plugin, e.ExitCode)
+}
+
+// FindPlugins returns the plugins found in the directories of pathList, a
+// list in the format of the PATH environment variable. When the same plugin
+// exists in several directories, the first one wins, as it does for the
+// shell. The result is sorted by name.
+func FindPlugins(pathList string) []Plugin {
+	found := make(map[string]Plugin)
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if _, seen := found[name]; seen {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			found[name] = Plugin{Name: name, Path: path}
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(found))
+	for _, plugin := range found {
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// pluginName returns the command name of a plugin executable file name.
+func pluginName(fileName string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		ext := filepath.Ext(fileName)
+		if !strings.EqualFold(ext, ".exe") {
+			return "", false
+		}
+		fileName = strings.TrimSuffix(fileName, ext)
+	}
+	if !strings.HasPrefix(fileName, PluginPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(fileName, PluginPrefix)
+	return name, name != ""
+}
+
+// isExecutable reports whether path is a regular file the user may execute.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0o111 != 0
+}
+
+// RunPlugin runs plugin with args, writing a PluginRequest for pkg to its
+// stdin and connecting its stdout and stderr to the given writers. A plugin
+// that exits with a non-zero status returns a *PluginError.
+func RunPlugin(ctx context.Context, plugin Plugin, pkg *Package, args []string, stdout, stderr io.Writer) error {
+	if args == nil {
+		args = []string{}
+	}
+	request, err := json.Marshal(PluginRequest{
+		Protocol: PluginProtocolVersion,
+		Version:  Version,
+		Args:     args,
+		Package:  pkg,
+	})
+	if err != nil {
+		return fmt.Errorf("RunPlugin(): %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, plugin.Path, args...)
+	cmd.Env = append(os.Environ(), PluginProtocolEnv+"="+strconv.Itoa(PluginProtocolVersion))
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+			return &PluginError{Plugin: plugin.Name, ExitCode: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("RunPlugin(): %s: %w", plugin.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package peekr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubPluginEnv makes the test binary act as a plugin instead of running the
+// tests, so the protocol can be tested with a real executable.
+const stubPluginEnv = "PEEKR_TEST_STUB_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubPluginEnv) == "1" {
+		os.Exit(runStubPlugin())
+	}
+	os.Exit(m.Run())
+}
+
+// runStubPlugin reads a PluginRequest from stdin and summarizes it on stdout.
+// It exits with status 3 when its first argument is "fail".
+func runStubPlugin() int {
+	var request PluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(request.Args) > 0 && request.Args[0] == "fail" {
+		fmt.Fprintln(os.Stderr, "failing on request")
+		return 3
+	}
+
+	var names []string
+	for _, symbol := range request.Package.Symbols {
+		names = append(names, symbol.Name)
+	}
+	fmt.Printf("protocol=%d env=%s version=%s package=%s symbols=%s args=%s\n",
+		request.Protocol, os.Getenv(PluginProtocolEnv), request.Version, request.Package.Name,
+		strings.Join(names, ","), strings.Join(os.Args[1:], ","))
+	return 0
+}
+
+// installStubPlugin copies the test binary into dir as the plugin name.
+func installStubPlugin(t *testing.T, dir, name string) string {
+	t.Helper()
+	self, err := os.Executable()
+	require.NoError(t, err)
+	data, err := os.ReadFile(self)
+	require.NoError(t, err)
+
+	fileName := PluginPrefix + name
+	if runtime.GOOS == "windows" {
+		fileName += ".exe"
+	}
+	path := filepath.Join(dir, fileName)
+	require.NoError(t, os.WriteFile(path, data, 0o755))
+	return path
+}
+
+func TestFindPlugins(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+	stub := installStubPlugin(t, first, "stub")
+	installStubPlugin(t, second, "stub")
+	other := installStubPlugin(t, second, "other")
+	writeTree(t, second, map[string]string{"peekr-readme.txt": "not executable"})
+	require.NoError(t, os.Mkdir(filepath.Join(second, PluginPrefix+"dir"), 0o755))
+
+	plugins := FindPlugins(strings.Join([]string{first, "", filepath.Join(first, "missing"), second}, string(os.PathListSeparator)))
+	assert.Equal(t, []Plugin{{Name: "other", Path: other}, {Name: "stub", Path: stub}}, plugins)
+}
+
+func TestRunPlugin(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":   "module example.com/plug\n",
+		"lib/a.go": "package lib\n\nfunc A() {}\n\ntype B struct{}\n",
+	})
+	pkg, err := LoadPackage(root, "lib", Options{})
+	require.NoError(t, err)
+
+	plugin := Plugin{Name: "stub", Path: installStubPlugin(t, t.TempDir(), "stub")}
+	t.Setenv(stubPluginEnv, "1")
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, RunPlugin(context.Background(), plugin, pkg, []string{"--top", "3"}, &stdout, &stderr))
+	assert.Equal(t, "protocol=1 env=1 version="+Version+" package=lib symbols=A,B args=--top,3\n", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	err = RunPlugin(context.Background(), plugin, pkg, []string{"fail"}, &stdout, &stderr)
+	var pluginErr *PluginError
+	require.ErrorAs(t, err, &pluginErr)
+	assert.Equal(t, 3, pluginErr.ExitCode)
+	assert.Equal(t, "failing on request\n", stderr.String())
+}
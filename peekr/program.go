@@ -0,0 +1,232 @@
+package peekr
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// program is every package of a workspace, type-checked together. It backs
+// the queries that need to see across package boundaries, such as finding
+// implementations or references.
+type program struct {
+	fset        *token.FileSet
+	ws          *Workspace
+	packages    []*typedPackage         // Checked packages, sorted by import path
+	workspace   map[*types.Package]bool // Packages of the main workspace, without consumers
+	importer    types.Importer          // Checks further packages on demand, such as stdlib ones
+	diagnostics []Diagnostic            // Parse and type errors
+}
+
+// loadProgram parses and type-checks every package of the workspace
+// containing opts.Dir. Test files and external test packages are included
+// when opts.Tests is set; vendored packages are only checked as far as
+// workspace packages import them. Like Options.TypeCheck, a program is
+// checked for a single build target, the host platform by default.
+//
+// The packages of the workspaces containing the consumers directories are
+// checked as well, resolving imports of the main workspace to its sources,
+// but they are not part of the main workspace: they only contribute
+// references.
+func loadProgram(ctx context.Context, opts Options, consumers ...string) (*program, error) {
+	opts = opts.typeCheckTarget()
+	ws, err := LoadWorkspace(opts.Dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The loader resolves imports through the modules of every workspace,
+	// the main one first so that it wins when several declare a module.
+	walkOpts := opts
+	walkOpts.Vendor = false
+	combined := &Workspace{Dir: ws.Dir, GoWork: ws.GoWork, Modules: append([]Module(nil), ws.Modules...)}
+	files, diags, err := ws.sourceFiles(ctx, walkOpts)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range consumers {
+		cws, err := LoadWorkspace(dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		combined.Modules = append(combined.Modules, cws.Modules...)
+		consumerFiles, consumerDiags, err := cws.sourceFiles(ctx, walkOpts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, consumerFiles...)
+		diags = append(diags, consumerDiags...)
+	}
+	if files, err = filterBuildConstraints(files, opts); err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	parsed, err := parseFiles(ctx, fset, files, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, pf := range parsed {
+		diags = append(diags, pf.diagnostics...)
+	}
+
+	loader := newTypeLoader(ctx, fset, combined, opts)
+	checked, err := loader.checkParsed(parsed)
+	if err != nil {
+		return nil, err
+	}
+	workspace := make(map[*types.Package]bool)
+	for _, tp := range checked {
+		diags = append(diags, tp.diagnostics...)
+		if _, ok := ws.importPathOf(tp.dir); ok || len(consumers) == 0 {
+			workspace[tp.types] = true
+		}
+	}
+
+	return &program{fset: fset, ws: ws, packages: checked, workspace: workspace, importer: loader, diagnostics: sortDiagnostics(diags)}, nil
+}
+
+// inWorkspace reports whether pkg is one of the workspace packages.
+func (p *program) inWorkspace(pkg *types.Package) bool {
+	return p.workspace[pkg]
+}
+
+// workspacePackages returns the checked packages of the main workspace.
+func (p *program) workspacePackages() []*typedPackage {
+	var packages []*typedPackage
+	for _, tp := range p.packages {
+		if p.inWorkspace(tp.types) {
+			packages = append(packages, tp)
+		}
+	}
+	return packages
+}
+
+// selectPackages returns the workspace packages called pkg, which is a
+// package name or an import path, the way Options.Package selects them.
+func (p *program) selectPackages(pkg string) []*typedPackage {
+	var packages []*typedPackage
+	for _, tp := range p.workspacePackages() {
+		if tp.path == pkg || (!isImportPath(pkg) && tp.types.Name() == pkg) {
+			packages = append(packages, tp)
+		}
+	}
+	return packages
+}
+
+// allPackages returns the workspace packages followed by every package they
+// import, directly or indirectly, each exactly once.
+func (p *program) allPackages() []*types.Package {
+	seen := make(map[*types.Package]bool)
+	var all []*types.Package
+	var visit func(pkg *types.Package)
+	visit = func(pkg *types.Package) {
+		if pkg == nil || seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		all = append(all, pkg)
+		for _, imp := range pkg.Imports() {
+			visit(imp)
+		}
+	}
+	for _, tp := range p.packages {
+		visit(tp.types)
+	}
+	return all
+}
+
+// lookupType finds the type named by name, as lookupObject does.
+func (p *program) lookupType(name string) (*types.TypeName, error) {
+	obj, _, err := p.lookupObject(name)
+	if err != nil {
+		return nil, err
+	}
+	typeName, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("lookupType(): %s is not a type", name)
+	}
+	return typeName, nil
+}
+
+// lookupObject finds the object named by name: "pkg.Name" for a package-level
+// declaration or "pkg.Type.Name" for a method or field, where pkg is a
+// package name or import path, or the name of a predeclared type such as
+// "error". Workspace packages are preferred over the packages they import.
+// It also returns the package-qualified name of the object. An error is
+// returned when no object matches or several do.
+func (p *program) lookupObject(name string) (types.Object, string, error) {
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		if obj, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+			return obj, name, nil
+		}
+		return nil, "", fmt.Errorf("lookupObject(): %q must be qualified by its package, e.g. pkg.%s", name, name)
+	}
+
+	type match struct {
+		obj  types.Object
+		name string
+	}
+	var local, external []match
+	add := func(pkg *types.Package, obj types.Object, name string) {
+		if p.inWorkspace(pkg) {
+			local = append(local, match{obj, name})
+		} else {
+			external = append(external, match{obj, name})
+		}
+	}
+	named := func(pkg *types.Package, pkgName string) bool {
+		return pkg.Path() == pkgName || pkg.Name() == pkgName
+	}
+
+	pkgName, objName := name[:dot], name[dot+1:]
+	typeDot := strings.LastIndex(pkgName, ".")
+	for _, pkg := range p.allPackages() {
+		if named(pkg, pkgName) {
+			if obj := pkg.Scope().Lookup(objName); obj != nil {
+				add(pkg, obj, qualifiedName(obj))
+			}
+		}
+		if typeDot < 0 || !named(pkg, pkgName[:typeDot]) {
+			continue
+		}
+		typeName, ok := pkg.Scope().Lookup(pkgName[typeDot+1:]).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		member, _, _ := types.LookupFieldOrMethod(typeName.Type(), true, pkg, objName)
+		if member != nil {
+			add(pkg, member, qualifiedName(typeName)+"."+member.Name())
+		}
+	}
+
+	matches := local
+	if len(matches) == 0 {
+		matches = external
+	}
+	switch len(matches) {
+	case 0:
+		return nil, "", fmt.Errorf("lookupObject(): %q not found", name)
+	case 1:
+		return matches[0].obj, matches[0].name, nil
+	}
+	candidates := make([]string, len(matches))
+	for i, m := range matches {
+		candidates[i] = m.name
+	}
+	sort.Strings(candidates)
+	return nil, "", fmt.Errorf("lookupObject(): %q is ambiguous, use the import path: %s", name, strings.Join(candidates, ", "))
+}
+
+// qualifiedName returns the name of a package-level object qualified by the
+// import path of its package, or just its name for predeclared objects.
+func qualifiedName(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
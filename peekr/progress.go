@@ -0,0 +1,38 @@
+package peekr
+
+import "sync"
+
+// ProgressKind says what a ProgressEvent reports.
+type ProgressKind string
+
+// Define constants for the kinds of progress events.
+const (
+	ProgressDiscovered ProgressKind = "discovered" // A source file was found
+	ProgressParsed     ProgressKind = "parsed"     // A source file was parsed without problems
+	ProgressFailed     ProgressKind = "failed"     // A source file could not be read or had syntax errors
+)
+
+// ProgressEvent reports progress while a package is loaded. Discovery events
+// arrive while directories are walked, before the total is known; parse
+// events follow, one per file, in whatever order the workers finish.
+type ProgressEvent struct {
+	Kind  ProgressKind
+	File  string // File the event is about
+	Count int    // Files discovered so far, or files parsed or failed so far
+	Total int    // Files to parse; 0 for discovery events
+}
+
+// progress returns a function that reports events to opts.Progress, never
+// calling it from two goroutines at once. It does nothing when
+// opts.Progress is nil.
+func (opts Options) progress() func(ProgressEvent) {
+	if opts.Progress == nil {
+		return func(ProgressEvent) {}
+	}
+	var mu sync.Mutex
+	return func(ev ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		opts.Progress(ev)
+	}
+}
@@ -0,0 +1,69 @@
+package peekr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProgress(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/prog\n",
+		"prog/bad.go": "package prog\n\nfunc Bad( {\n",
+	}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("prog/f%d.go", i)] = fmt.Sprintf("package prog\n\nfunc F%d() {}\n", i)
+	}
+	writeTree(t, root, files)
+
+	counts := make(map[ProgressKind]int)
+	var last ProgressEvent
+	opts := Options{Dir: root, Package: "prog", Workers: 4, Progress: func(ev ProgressEvent) {
+		counts[ev.Kind]++
+		if ev.Kind != ProgressDiscovered {
+			assert.Equal(t, 11, ev.Total)
+			last = ev
+		}
+	}}
+	_, err := Load(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[ProgressKind]int{ProgressDiscovered: 11, ProgressParsed: 10, ProgressFailed: 1}, counts)
+	assert.Equal(t, 11, last.Count)
+}
+
+func TestLoadCancellation(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{"go.mod": "module example.com/slow\n"}
+	for i := 0; i < 50; i++ {
+		files[fmt.Sprintf("slow/f%02d.go", i)] = "package slow\n"
+	}
+	writeTree(t, root, files)
+
+	t.Run("Cancelled while parsing", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		parsed := 0
+		opts := Options{Dir: root, Package: "slow", Workers: 1, Progress: func(ev ProgressEvent) {
+			if ev.Kind == ProgressParsed {
+				parsed++
+				cancel()
+			}
+		}}
+		_, err := Load(ctx, opts)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, parsed, 50)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+		_, err := Load(ctx, Options{Dir: root, Package: "slow"})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
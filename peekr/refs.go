@@ -0,0 +1,264 @@
+package peekr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// Reference is a use of a symbol found by FindReferences.
+type Reference struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Package  string `json:"package"`            // Import path of the referring package
+	Function string `json:"function,omitempty"` // Enclosing function, e.g. "cmd.Execute" or "(*peekr.Options).workers"; empty at package level
+	Call     bool   `json:"call"`               // Whether the reference calls the symbol
+}
+
+// References is the result of FindReferences.
+type References struct {
+	Query       string       `json:"query"` // Package-qualified name of the symbol
+	File        string       `json:"file,omitempty"`
+	Line        int          `json:"line,omitempty"`
+	References  []Reference  `json:"references"` // Sorted by file and position
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// FindReferences type-checks every package of the workspace containing
+// opts.Dir and returns every reference to the symbol called name: "pkg.Name"
+// for a package-level declaration or "pkg.Type.Name" for a method or field,
+// by package name or import path. The declaration itself is not a reference.
+func FindReferences(ctx context.Context, opts Options, name string) (*References, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("FindReferences(): Options.Dir is required")
+	}
+	prog, err := loadProgram(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	target, query, err := prog.lookupObject(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &References{Query: query, Diagnostics: prog.diagnostics}
+	if target.Pkg() != nil {
+		pos := prog.fset.Position(target.Pos())
+		result.File, result.Line = pos.Filename, pos.Line
+	}
+	prog.references(func(ref Reference, obj types.Object) {
+		if obj == target {
+			result.References = append(result.References, ref)
+		}
+	})
+	sort.SliceStable(result.References, func(i, j int) bool {
+		a, b := result.References[i], result.References[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return result, nil
+}
+
+// references calls visit for every identifier of the workspace that refers
+// to an object declared elsewhere. Objects of instantiated generic functions,
+// methods and fields are replaced by their generic origin.
+func (p *program) references(visit func(ref Reference, obj types.Object)) {
+	for _, tp := range p.packages {
+		for _, file := range tp.files {
+			calls := calledIdents(file)
+			enclosing := funcRanges(file, tp.info)
+			ast.Inspect(file, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				obj := tp.info.Uses[ident]
+				if obj == nil {
+					return true
+				}
+				pos := p.fset.Position(ident.Pos())
+				visit(Reference{
+					File:     pos.Filename,
+					Line:     pos.Line,
+					Column:   pos.Column,
+					Package:  tp.path,
+					Function: enclosing.at(ident.Pos()),
+					Call:     calls[ident],
+				}, originObject(obj))
+				return true
+			})
+		}
+	}
+}
+
+// referenceCounts returns how often every object of the workspace is
+// referenced, keyed by the positionKey of its declaration.
+func (p *program) referenceCounts() map[string]int {
+	counts := make(map[string]int)
+	p.references(func(_ Reference, obj types.Object) {
+		if obj.Pkg() == nil || !p.inWorkspace(obj.Pkg()) {
+			return
+		}
+		pos := p.fset.Position(obj.Pos())
+		counts[positionKey(pos.Filename, pos.Line, pos.Column)]++
+	})
+	return counts
+}
+
+// attachUsage records on every symbol of pkg, and on the fields of its
+// structs, how often it is referenced across the workspace.
+func attachUsage(ctx context.Context, pkg *Package, opts Options) error {
+	opts.Dir = pkg.Dir
+	opts.Progress = nil
+	prog, err := loadProgram(ctx, opts)
+	if err != nil {
+		return err
+	}
+	counts := prog.referenceCounts()
+	count := func(symbol *Symbol) {
+		n := counts[positionKey(symbol.File, symbol.Line, symbol.Column)]
+		symbol.References = &n
+	}
+	for i := range pkg.Symbols {
+		count(&pkg.Symbols[i])
+		for j := range pkg.Symbols[i].Children {
+			count(&pkg.Symbols[i].Children[j])
+		}
+	}
+	return nil
+}
+
+// originObject returns the generic object obj was instantiated from, or obj
+// itself.
+func originObject(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// calledIdents returns the identifiers that name the function of a call,
+// such as F in F(x) and M in v.M(x) or pkg.F[int](x).
+func calledIdents(file *ast.File) map[*ast.Ident]bool {
+	calls := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		fun := ast.Unparen(call.Fun)
+		switch index := fun.(type) {
+		case *ast.IndexExpr:
+			fun = index.X
+		case *ast.IndexListExpr:
+			fun = index.X
+		}
+		switch fun := fun.(type) {
+		case *ast.Ident:
+			calls[fun] = true
+		case *ast.SelectorExpr:
+			calls[fun.Sel] = true
+		}
+		return true
+	})
+	return calls
+}
+
+// funcRange is the extent of a function declaration.
+type funcRange struct {
+	pos, end token.Pos
+	name     string
+}
+
+// funcRangeList holds the function declarations of a file in source order.
+type funcRangeList []funcRange
+
+// funcRanges returns the function declarations of file.
+func funcRanges(file *ast.File, info *types.Info) funcRangeList {
+	var ranges funcRangeList
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			if obj, ok := info.Defs[fn.Name].(*types.Func); ok {
+				ranges = append(ranges, funcRange{pos: fn.Pos(), end: fn.End(), name: funcDisplayName(obj)})
+			}
+		}
+	}
+	return ranges
+}
+
+// at returns the name of the function declaration containing pos, or an
+// empty string at package level.
+func (ranges funcRangeList) at(pos token.Pos) string {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].end >= pos })
+	if i < len(ranges) && ranges[i].pos <= pos {
+		return ranges[i].name
+	}
+	return ""
+}
+
+// funcDisplayName names a function the way it is written in Go code outside
+// its package, e.g. "helpers.TerminalColor" or "(*peekr.Options).workers".
+func funcDisplayName(fn *types.Func) string {
+	sig := fn.Type().(*types.Signature)
+	if recv := sig.Recv(); recv != nil {
+		return "(" + types.TypeString(recv.Type(), packageNameQualifier) + ")." + fn.Name()
+	}
+	if fn.Pkg() == nil {
+		return fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
+
+// packageNameQualifier qualifies types by package name rather than import path.
+func packageNameQualifier(pkg *types.Package) string {
+	return pkg.Name()
+}
+
+// PrintReferences prints the result of FindReferences.
+func PrintReferences(result *References) {
+	writeReferences(os.Stdout, result)
+}
+
+// writeReferences writes the references of result to w, one per line with
+// the enclosing function of each.
+func writeReferences(w io.Writer, result *References) {
+	if len(result.References) == 0 {
+		helpers.FprintColor(w, fmt.Sprintf("\nNo references to '%s'.", result.Query), helpers.Error)
+		return
+	}
+	noun := "references"
+	if len(result.References) == 1 {
+		noun = "reference"
+	}
+	header := fmt.Sprintf("\n%d %s to '%s':", len(result.References), noun, result.Query)
+	helpers.FprintColor(w, header, helpers.Info)
+
+	for _, ref := range result.References {
+		function := ref.Function
+		if function == "" {
+			function = "(package level)"
+		}
+		line := fmt.Sprintf("  %s:%d:%d  %s", ref.File, ref.Line, ref.Column, function)
+		if ref.Call {
+			line += "  [call]"
+		}
+		helpers.FprintColor(w, line, helpers.Debug)
+	}
+}
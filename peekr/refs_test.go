@@ -0,0 +1,110 @@
+package peekr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRefsTree writes a module in which util is used by app.
+func writeRefsTree(t *testing.T) string {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/refs\n",
+		"util/util.go": `package util
+
+type Config struct {
+	Name  string
+	Debug bool
+}
+
+func (c *Config) Label() string { return c.Name }
+
+func Map[T any](xs []T, f func(T) T) []T { return xs }
+
+func Unused() {}
+`,
+		"app/app.go": `package app
+
+import "example.com/refs/util"
+
+var Default = util.Config{Name: "default"}
+
+func Run() string {
+	cfg := &util.Config{}
+	util.Map([]int{1}, func(i int) int { return i })
+	return cfg.Label() + Default.Label()
+}
+`,
+	})
+	return root
+}
+
+func TestFindReferences(t *testing.T) {
+	root := writeRefsTree(t)
+	opts := Options{Dir: root}
+
+	result, err := FindReferences(context.Background(), opts, "util.Config")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/refs/util.Config", result.Query)
+	assert.Equal(t, 3, result.Line)
+	require.Len(t, result.References, 3)
+	assert.Equal(t, Reference{File: result.References[0].File, Line: 5, Column: 20, Package: "example.com/refs/app"}, result.References[0])
+	assert.Equal(t, "app.Run", result.References[1].Function)
+	assert.False(t, result.References[1].Call)
+	assert.Equal(t, "(*util.Config).Label", result.References[2].Function)
+
+	result, err = FindReferences(context.Background(), opts, "example.com/refs/util.Config.Label")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/refs/util.Config.Label", result.Query)
+	require.Len(t, result.References, 2)
+	for _, ref := range result.References {
+		assert.True(t, ref.Call)
+		assert.Equal(t, "app.Run", ref.Function)
+	}
+
+	result, err = FindReferences(context.Background(), opts, "util.Config.Name")
+	require.NoError(t, err)
+	require.Len(t, result.References, 2)
+	assert.Equal(t, "", result.References[0].Function)
+	assert.Equal(t, "(*util.Config).Label", result.References[1].Function)
+
+	result, err = FindReferences(context.Background(), opts, "util.Map")
+	require.NoError(t, err)
+	require.Len(t, result.References, 1)
+	assert.True(t, result.References[0].Call)
+
+	result, err = FindReferences(context.Background(), opts, "util.Unused")
+	require.NoError(t, err)
+	assert.Empty(t, result.References)
+}
+
+func TestLoadUsage(t *testing.T) {
+	root := writeRefsTree(t)
+
+	pkg, err := Load(context.Background(), Options{Dir: root, Package: "util", Usage: true})
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	for _, symbol := range pkg.Symbols {
+		require.NotNil(t, symbol.References, symbol.Name)
+		counts[symbol.Name] = *symbol.References
+		for _, field := range symbol.Children {
+			counts[symbol.Name+"."+field.Name] = *field.References
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"Config":       3,
+		"Config.Name":  2,
+		"Config.Debug": 0,
+		"Label":        2,
+		"Map":          1,
+		"Unused":       0,
+	}, counts)
+
+	pkg, err = Load(context.Background(), Options{Dir: root, Package: "util"})
+	require.NoError(t, err)
+	assert.Nil(t, pkg.Symbols[0].References)
+}
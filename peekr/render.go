@@ -0,0 +1,126 @@
+package peekr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultFormat is the renderer used when no format is requested.
+const DefaultFormat = "text"
+
+// Renderer writes a loaded package to w in some output format. Renderers
+// never write anywhere but w, so they can be used for files, buffers and
+// network responses as well as the terminal.
+type Renderer interface {
+	Render(w io.Writer, pkg *Package, opts RenderOptions) error
+}
+
+// RendererFunc adapts an ordinary function to the Renderer interface.
+type RendererFunc func(w io.Writer, pkg *Package, opts RenderOptions) error
+
+// Render calls f(w, pkg, opts).
+func (f RendererFunc) Render(w io.Writer, pkg *Package, opts RenderOptions) error {
+	return f(w, pkg, opts)
+}
+
+// RenderOptions selects what a renderer includes.
+type RenderOptions struct {
+	Functions bool        // Include functions and methods
+	Structs   bool        // Include structs and their fields
+	Diff      *SymbolDiff // Changes to highlight, if the format supports it; may be nil
+}
+
+// includes reports whether a top-level symbol of the given kind is selected.
+func (opts RenderOptions) includes(kind SymbolKind) bool {
+	switch kind {
+	case FuncSymbol:
+		return opts.Functions
+	case StructSymbol:
+		return opts.Structs
+	default:
+		return false
+	}
+}
+
+// symbols returns the symbols of pkg selected by opts.
+func (opts RenderOptions) symbols(pkg *Package) []Symbol {
+	selected := make([]Symbol, 0, len(pkg.Symbols))
+	for _, symbol := range pkg.Symbols {
+		if opts.includes(symbol.Kind) {
+			selected = append(selected, symbol)
+		}
+	}
+	return selected
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = make(map[string]Renderer)
+)
+
+// RegisterRenderer makes a renderer available under name, for example to the
+// --format flag. It is meant to be called from init functions, and panics if
+// r is nil or if a renderer is already registered under name.
+func RegisterRenderer(name string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	if r == nil {
+		panic("peekr: RegisterRenderer renderer is nil")
+	}
+	if _, dup := renderers[name]; dup {
+		panic("peekr: RegisterRenderer called twice for renderer " + name)
+	}
+	renderers[name] = r
+}
+
+// LookupRenderer returns the renderer registered under name.
+func LookupRenderer(name string) (Renderer, error) {
+	renderersMu.RLock()
+	r, ok := renderers[name]
+	renderersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(RendererNames(), ", "))
+	}
+	return r, nil
+}
+
+// RendererNames returns the names of all registered renderers, sorted.
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterRenderer("text", RendererFunc(renderText))
+	RegisterRenderer("json", RendererFunc(renderJSON))
+	RegisterRenderer("markdown", RendererFunc(renderMarkdown))
+}
+
+// renderText writes the color-coded listing printed by the list command,
+// followed by any diagnostics.
+func renderText(w io.Writer, pkg *Package, opts RenderOptions) error {
+	writePackage(w, pkg, opts)
+	writeDiagnostics(w, pkg.Diagnostics)
+	return nil
+}
+
+// renderJSON writes the package as an indented JSON document, keeping only
+// the selected kinds of symbols.
+func renderJSON(w io.Writer, pkg *Package, opts RenderOptions) error {
+	selected := *pkg
+	selected.Symbols = opts.symbols(pkg)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(selected)
+}
@@ -0,0 +1,108 @@
+package peekr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderers(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":   "module example.com/render\n",
+		"lib/a.go": "package lib\n\n// Run runs | things.\nfunc Run(n int) error { return nil }\n\n// Config configures Run.\ntype Config struct {\n\t// Name | label.\n\tName string\n}\n",
+		"lib/b.go": "package lib\n\nfunc Broken( {\n",
+	})
+	pkg, err := LoadPackage(root, "lib", Options{})
+	require.NoError(t, err)
+	all := RenderOptions{Functions: true, Structs: true}
+
+	t.Run("JSON", func(t *testing.T) {
+		r, err := LookupRenderer("json")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, r.Render(&buf, pkg, RenderOptions{Structs: true}))
+		var decoded Package
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, pkg.Name, decoded.Name)
+		assert.Equal(t, pkg.Diagnostics, decoded.Diagnostics)
+		require.Len(t, decoded.Symbols, 1)
+		assert.Equal(t, pkg.Symbols[1], decoded.Symbols[0])
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		r, err := LookupRenderer("markdown")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, r.Render(&buf, pkg, all))
+		out := buf.String()
+		assert.Contains(t, out, "# Package `lib`\n")
+		assert.Contains(t, out, "### lib/a.go\n")
+		assert.Contains(t, out, "```go\nfunc Run(n int) error\n```\n\nRun runs | things.\n")
+		assert.Contains(t, out, "| `Name` | `string` | Name \\| label. |\n")
+		assert.Contains(t, out, "## Diagnostics\n")
+		assert.NotContains(t, out, "_generated_")
+	})
+
+	t.Run("Markdown generated marker", func(t *testing.T) {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{
+			"go.mod":     "module example.com/gen\n",
+			"gen/gen.go": "// Code generated by stringer. DO NOT EDIT.\n\npackage gen\n\nfunc Gen() {}\n",
+		})
+		pkg, err := LoadPackage(root, "gen", Options{})
+		require.NoError(t, err)
+		r, err := LookupRenderer("markdown")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, r.Render(&buf, pkg, all))
+		assert.Contains(t, buf.String(), "#### Gen\n\n```go\nfunc Gen()\n```\n\n_generated_\n")
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		r, err := LookupRenderer("text")
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, r.Render(&buf, pkg, RenderOptions{Functions: true}))
+		out := buf.String()
+		assert.Contains(t, out, "Run(n int) error")
+		assert.NotContains(t, out, "Structs in the")
+		assert.Contains(t, out, "problem(s) found")
+	})
+
+	t.Run("Registry", func(t *testing.T) {
+		_, err := LookupRenderer("nope")
+		assert.EqualError(t, err, `unknown format "nope" (available: json, markdown, text)`)
+
+		names := func(w io.Writer, pkg *Package, opts RenderOptions) error {
+			for _, symbol := range opts.symbols(pkg) {
+				io.WriteString(w, symbol.Name+"\n")
+			}
+			return nil
+		}
+		RegisterRenderer("test-names", RendererFunc(names))
+		defer func() {
+			renderersMu.Lock()
+			delete(renderers, "test-names")
+			renderersMu.Unlock()
+		}()
+
+		r, err := LookupRenderer("test-names")
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, r.Render(&buf, pkg, all))
+		assert.Equal(t, "Run\nConfig\nBroken\n", buf.String())
+		assert.Contains(t, RendererNames(), "test-names")
+
+		assert.Panics(t, func() { RegisterRenderer("test-names", RendererFunc(names)) })
+		assert.Panics(t, func() { RegisterRenderer("test-nil", nil) })
+	})
+}
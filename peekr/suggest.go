@@ -0,0 +1,176 @@
+package peekr
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// ExitPackageNotFound is the exit status used when the requested package
+// does not exist, so scripts can tell a typo apart from other failures.
+const ExitPackageNotFound = 2
+
+// maxSuggestions limits how many "did you mean" candidates are offered.
+const maxSuggestions = 5
+
+// PackageNotFoundError is returned when no file under the scanned directory
+// declares the requested package. It is distinct from a package that exists
+// but has no exported symbols, which is not an error.
+type PackageNotFoundError struct {
+	Package     string       // Package name or import path that was requested
+	Dir         string       // Directory that was scanned
+	Suggestions []string     // Closest existing packages, best match first
+	Diagnostics []Diagnostic // Problems found while looking for the package
+}
+
+// Error implements the error interface.
+func (e *PackageNotFoundError) Error() string {
+	msg := fmt.Sprintf("package %q not found in %s", e.Package, e.Dir)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf("; did you mean %q?", e.Suggestions[0])
+	}
+	return msg
+}
+
+// newPackageNotFoundError builds a PackageNotFoundError with suggestions.
+// Failing to compute suggestions is not fatal; the error is still returned.
+// Looking for suggestions is not reported as progress.
+func newPackageNotFoundError(dir, pkg string, opts Options) *PackageNotFoundError {
+	opts.Progress = nil
+	suggestions, _ := SuggestPackages(dir, pkg, opts)
+	return &PackageNotFoundError{Package: pkg, Dir: dir, Suggestions: suggestions}
+}
+
+// SuggestPackages returns the packages under dir whose names are closest to
+// pkg by edit distance, best match first. When pkg is an import path, import
+// paths are compared instead of package names. Candidates that are too far
+// from pkg to be a plausible typo are left out.
+func SuggestPackages(dir, pkg string, opts Options) ([]string, error) {
+	ws, err := LoadWorkspace(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	files, _, err := ws.sourceFiles(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	byImportPath := isImportPath(pkg)
+	candidates := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parser.PackageClauseOnly)
+		if err != nil || f.Name == nil || f.Name.Name == "" {
+			continue
+		}
+		if !byImportPath {
+			candidates[f.Name.Name] = true
+			continue
+		}
+		if importPath, ok := ws.importPathOf(filepath.Dir(file)); ok {
+			candidates[importPath] = true
+		}
+	}
+
+	type ranked struct {
+		name     string
+		distance int
+	}
+	// The allowed distance grows with the length of the last path element, so
+	// long import paths do not match every sibling package.
+	query := strings.ToLower(pkg)
+	threshold := len(path.Base(query))/3 + 1
+	var matches []ranked
+	for name := range candidates {
+		if name == pkg {
+			continue
+		}
+		distance := levenshtein(query, strings.ToLower(name))
+		if distance <= threshold {
+			matches = append(matches, ranked{name: name, distance: distance})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var suggestions []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, matches[i].name)
+	}
+	return suggestions, nil
+}
+
+// importPathOf returns the import path of a package directory inside one of
+// the workspace modules.
+func (ws *Workspace) importPathOf(pkgDir string) (string, bool) {
+	for _, mod := range ws.Modules {
+		rel, err := filepath.Rel(mod.Dir, pkgDir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rel == "." {
+			return mod.Path, true
+		}
+		return mod.Path + "/" + filepath.ToSlash(rel), true
+	}
+	return "", false
+}
+
+// levenshtein returns the edit distance between a and b: the number of
+// single-character insertions, deletions or substitutions to turn one into the other.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// minInt returns the smallest of its arguments.
+func minInt(first int, rest ...int) int {
+	for _, v := range rest {
+		if v < first {
+			first = v
+		}
+	}
+	return first
+}
+
+// printPackageNotFound prints a color-coded "package not found" message with
+// any suggestions.
+func printPackageNotFound(err *PackageNotFoundError) {
+	header := fmt.Sprintf("\nPackage %s was not found in %s.", fmt.Sprintf("'%s'", err.Package), err.Dir)
+	helpers.TerminalColor(header, helpers.Error)
+	if len(err.Suggestions) == 0 {
+		return
+	}
+	helpers.TerminalColor("\nDid you mean:", helpers.Notice)
+	for _, suggestion := range err.Suggestions {
+		helpers.TerminalColor("  "+suggestion, helpers.Debug)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,67 @@
+package peekr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"helpers", "helpers", 0},
+		{"helper", "helpers", 1},
+		{"hlepers", "helpers", 2},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			assert.Equal(t, tc.expected, levenshtein(tc.a, tc.b))
+		})
+	}
+}
+
+func TestPackageNotFound(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":             "module example.com/sugg\n",
+		"helpers/helpers.go": "package helpers\n\nfunc Help() {}\n",
+		"helper2/helper2.go": "package helper2\n",
+		"empty/empty.go":     "package empty\n\nfunc unexported() {}\n",
+		"config/config.go":   "package config\n",
+	})
+
+	t.Run("Typo", func(t *testing.T) {
+		_, _, err := PackageFunctions(root, "helper", Options{})
+		var notFound *PackageNotFoundError
+		require.True(t, errors.As(err, &notFound))
+		assert.Equal(t, []string{"helper2", "helpers"}, notFound.Suggestions)
+	})
+
+	t.Run("Import path typo", func(t *testing.T) {
+		_, _, err := PackageStructs(root, "example.com/sugg/helper", Options{})
+		var notFound *PackageNotFoundError
+		require.True(t, errors.As(err, &notFound))
+		assert.Equal(t, []string{"example.com/sugg/helper2", "example.com/sugg/helpers"}, notFound.Suggestions)
+	})
+
+	t.Run("Nothing close", func(t *testing.T) {
+		_, _, err := PackageFunctions(root, "zzzzzzzz", Options{})
+		var notFound *PackageNotFoundError
+		require.True(t, errors.As(err, &notFound))
+		assert.Empty(t, notFound.Suggestions)
+	})
+
+	t.Run("Empty package is not an error", func(t *testing.T) {
+		funcMap, _, err := PackageFunctions(root, "empty", Options{})
+		require.NoError(t, err)
+		assert.Empty(t, funcMap)
+	})
+}
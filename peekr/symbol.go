@@ -0,0 +1,162 @@
+package peekr
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// SymbolKind says what kind of declaration a Symbol describes.
+type SymbolKind string
+
+// Define constants for the kinds of symbols peekr extracts.
+const (
+	FuncSymbol   SymbolKind = "func"   // A function or method
+	StructSymbol SymbolKind = "struct" // A struct type
+	FieldSymbol  SymbolKind = "field"  // A field of a struct, found in the struct's Children
+)
+
+// Symbol is a declaration extracted from a package. Functions, methods,
+// structs and struct fields all share this type, so everything that lists,
+// filters or renders symbols works the same way for each of them.
+type Symbol struct {
+	Kind      SymbolKind `json:"kind"`
+	Name      string     `json:"name"`
+	Receiver  string     `json:"receiver,omitempty"`  // Receiver type of a method, e.g. "*Options"
+	Package   string     `json:"package"`             // Package clause of the declaring file
+	File      string     `json:"file"`                // Path of the declaring file
+	Line      int        `json:"line"`                // 1-based line of the declaration
+	Column    int        `json:"column"`              // 1-based column of the declaration
+	Doc       string     `json:"doc,omitempty"`       // Doc comment text, without comment markers
+	Signature string     `json:"signature,omitempty"` // "(params) results" for functions, the type for fields
+	TestKind  TestKind   `json:"testKind,omitempty"`  // Set for functions found in _test.go files
+	Generated bool       `json:"generated,omitempty"` // Declared in a generated file
+	Children  []Symbol   `json:"children,omitempty"`  // Fields of a struct
+
+	// Set only when the package was loaded with Options.TypeCheck.
+	Type          string `json:"type,omitempty"`          // Resolved type, with aliases resolved and packages qualified by import path
+	Underlying    string `json:"underlying,omitempty"`    // Underlying type of Type
+	QualifiedName string `json:"qualifiedName,omitempty"` // e.g. "example.com/mod/pkg.Options" or "(*example.com/mod/pkg.Options).Load"
+
+	// References counts the references to the symbol across the workspace.
+	// It is nil unless the package was loaded with Options.Usage.
+	References *int `json:"references,omitempty"`
+}
+
+// ResolvedSignature returns the signature of a function or the type of a
+// field as resolved by the type checker, falling back to Signature as written
+// in the source when the package was not type-checked.
+func (s Symbol) ResolvedSignature() string {
+	if s.Type == "" {
+		return s.Signature
+	}
+	return strings.TrimPrefix(s.Type, "func")
+}
+
+// ID identifies a symbol within its package independently of its signature,
+// so a function whose parameters change keeps the same ID.
+func (s Symbol) ID() string {
+	name := s.Name
+	if s.Receiver != "" {
+		name = "(" + s.Receiver + ")." + name
+	}
+	return string(s.Kind) + " " + s.Package + "." + name
+}
+
+// extractSymbols collects every exported function, method and struct in the
+// loaded files, plus every function of _test.go files, sorted by file and
+// position.
+func extractSymbols(fset *token.FileSet, files []*parsedFile) []Symbol {
+	var symbols []Symbol
+	for _, pf := range files {
+		symbols = append(symbols, fileSymbols(fset, pf)...)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].File != symbols[j].File {
+			return symbols[i].File < symbols[j].File
+		}
+		return symbols[i].Line < symbols[j].Line
+	})
+	return symbols
+}
+
+// fileSymbols extracts the symbols declared in a single file.
+func fileSymbols(fset *token.FileSet, pf *parsedFile) []Symbol {
+	f := pf.file
+	testFile := isTestFile(pf.path)
+	newSymbol := func(kind SymbolKind, name *ast.Ident, doc *ast.CommentGroup) Symbol {
+		pos := fset.Position(name.Pos())
+		return Symbol{
+			Kind:      kind,
+			Name:      name.Name,
+			Package:   f.Name.Name,
+			File:      pf.path,
+			Line:      pos.Line,
+			Column:    pos.Column,
+			Doc:       doc.Text(),
+			Generated: pf.generated,
+		}
+	}
+
+	var symbols []Symbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !testFile && !d.Name.IsExported() {
+				continue
+			}
+			fn := newSymbol(FuncSymbol, d.Name, d.Doc)
+			fn.Signature = funcSignature(d.Type)
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				fn.Receiver = ExprToString(d.Recv.List[0].Type)
+			}
+			if testFile {
+				fn.TestKind = classifyTestFunc(d)
+			}
+			symbols = append(symbols, fn)
+
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok || !typeSpec.Name.IsExported() {
+					continue
+				}
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+
+				// A lone type spec is documented on the declaration; grouped
+				// specs carry their own doc comments.
+				doc := typeSpec.Doc
+				if doc == nil {
+					doc = d.Doc
+				}
+				st := newSymbol(StructSymbol, typeSpec.Name, doc)
+				for _, field := range structType.Fields.List {
+					for _, fieldName := range field.Names {
+						child := newSymbol(FieldSymbol, fieldName, field.Doc)
+						child.Signature = ExprToString(field.Type)
+						st.Children = append(st.Children, child)
+					}
+				}
+				symbols = append(symbols, st)
+			}
+		}
+	}
+	return symbols
+}
+
+// funcSignature formats the parameters and results of a function as
+// "(params) results".
+func funcSignature(ft *ast.FuncType) string {
+	signature := "(" + ExtractFuncParams(ft.Params) + ")"
+	if results := ExtractFuncResults(ft.Results); results != "" {
+		signature += " " + results
+	}
+	return signature
+}
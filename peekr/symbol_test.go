@@ -0,0 +1,68 @@
+package peekr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractSymbols(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/sym\n",
+		"sym/a.go": `package sym
+
+// Client talks to the server.
+type Client struct {
+	// Addr is the server address.
+	Addr    string
+	Retries int
+	private bool
+}
+
+// Do sends a request.
+func (c *Client) Do(req string) (int, error) { return 0, nil }
+
+func New() *Client { return nil }
+
+func hidden() {}
+
+type (
+	// Grouped is documented on its spec.
+	Grouped struct{}
+)
+`,
+	})
+	file := filepath.Join(root, "sym", "a.go")
+
+	pkg, err := LoadPackage(root, "sym", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []Symbol{
+		{
+			Kind: StructSymbol, Name: "Client", Package: "sym", File: file, Line: 4, Column: 6,
+			Doc: "Client talks to the server.\n",
+			Children: []Symbol{
+				{Kind: FieldSymbol, Name: "Addr", Package: "sym", File: file, Line: 6, Column: 2, Doc: "Addr is the server address.\n", Signature: "string"},
+				{Kind: FieldSymbol, Name: "Retries", Package: "sym", File: file, Line: 7, Column: 2, Signature: "int"},
+				{Kind: FieldSymbol, Name: "private", Package: "sym", File: file, Line: 8, Column: 2, Signature: "bool"},
+			},
+		},
+		{Kind: FuncSymbol, Name: "Do", Receiver: "*Client", Package: "sym", File: file, Line: 12, Column: 18, Doc: "Do sends a request.\n", Signature: "(req string) (int, error)"},
+		{Kind: FuncSymbol, Name: "New", Package: "sym", File: file, Line: 14, Column: 6, Signature: "() *Client"},
+		{Kind: StructSymbol, Name: "Grouped", Package: "sym", File: file, Line: 20, Column: 2, Doc: "Grouped is documented on its spec.\n"},
+	}, pkg.Symbols)
+
+	assert.Equal(t, "func sym.(*Client).Do", pkg.Symbols[1].ID())
+	assert.Equal(t, "struct sym.Client", pkg.Symbols[0].ID())
+
+	structsMap, _, err := PackageStructs(root, "sym", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "a", structsMap[file][0].FileName)
+
+	funcMap, _, err := PackageFunctions(root, "sym", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "req string", funcMap[file][0].Params)
+	assert.Equal(t, "(int, error)", funcMap[file][0].Returns)
+}
@@ -0,0 +1,86 @@
+package peekr
+
+import (
+	"go/ast"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TestKind classifies a function declared in a _test.go file.
+type TestKind string
+
+// Define constants for the kinds of test functions. Functions outside of
+// _test.go files have an empty TestKind.
+const (
+	TestKindTest      TestKind = "test"
+	TestKindHelper    TestKind = "helper"
+	TestKindExample   TestKind = "example"
+	TestKindBenchmark TestKind = "benchmark"
+	TestKindFuzz      TestKind = "fuzz"
+)
+
+// testSections lists the test kinds in the order they are printed, along with
+// the heading used for each section.
+var testSections = []struct {
+	Kind  TestKind
+	Title string
+}{
+	{TestKindHelper, "Test helpers"},
+	{TestKindTest, "Tests"},
+	{TestKindExample, "Examples"},
+	{TestKindBenchmark, "Benchmarks"},
+	{TestKindFuzz, "Fuzz targets"},
+}
+
+// classifyTestFunc determines the TestKind of a function declared in a
+// _test.go file, using the same naming and signature rules as 'go test'.
+// Anything that is not a test, example, benchmark or fuzz target is a helper.
+func classifyTestFunc(fn *ast.FuncDecl) TestKind {
+	if fn.Recv != nil {
+		return TestKindHelper
+	}
+
+	name := fn.Name.Name
+	switch {
+	case name == "TestMain" && hasTestingParam(fn, "M"):
+		return TestKindTest
+	case isTestName(name, "Test") && hasTestingParam(fn, "T"):
+		return TestKindTest
+	case isTestName(name, "Benchmark") && hasTestingParam(fn, "B"):
+		return TestKindBenchmark
+	case isTestName(name, "Fuzz") && hasTestingParam(fn, "F"):
+		return TestKindFuzz
+	case isTestName(name, "Example") && fn.Type.Params.NumFields() == 0 && fn.Type.Results.NumFields() == 0:
+		return TestKindExample
+	}
+	return TestKindHelper
+}
+
+// isTestName reports whether name starts with prefix and the rest of the name
+// does not start with a lower-case letter, so "Testify" is not a test.
+func isTestName(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// hasTestingParam reports whether fn takes exactly one parameter of type
+// *testing.<typeName>.
+func hasTestingParam(fn *ast.FuncDecl, typeName string) bool {
+	params := fn.Type.Params
+	if params.NumFields() != 1 {
+		return false
+	}
+	star, ok := params.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	typeString := ExprToString(star.X)
+	return typeString == typeName || strings.HasSuffix(typeString, "."+typeName)
+}
@@ -0,0 +1,107 @@
+package peekr
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyTestFunc(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected TestKind
+	}{
+		{
+			name:     "Test",
+			input:    "package test\nfunc TestFoo(t *testing.T) {}",
+			expected: TestKindTest,
+		},
+		{
+			name:     "TestMain",
+			input:    "package test\nfunc TestMain(m *testing.M) {}",
+			expected: TestKindTest,
+		},
+		{
+			name:     "Lower-case suffix is not a test",
+			input:    "package test\nfunc Testify(t *testing.T) {}",
+			expected: TestKindHelper,
+		},
+		{
+			name:     "Benchmark",
+			input:    "package test\nfunc BenchmarkFoo(b *testing.B) {}",
+			expected: TestKindBenchmark,
+		},
+		{
+			name:     "Fuzz",
+			input:    "package test\nfunc FuzzFoo(f *testing.F) {}",
+			expected: TestKindFuzz,
+		},
+		{
+			name:     "Example",
+			input:    "package test\nfunc ExampleFoo_bar() {}",
+			expected: TestKindExample,
+		},
+		{
+			name:     "Example with params is a helper",
+			input:    "package test\nfunc ExampleFoo(x int) {}",
+			expected: TestKindHelper,
+		},
+		{
+			name:     "Helper",
+			input:    "package test\nfunc writeTree(t *testing.T, root string) {}",
+			expected: TestKindHelper,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "", tc.input, 0)
+			if err != nil {
+				t.Fatalf("Failed to parse input: %s", err)
+			}
+
+			fn := file.Decls[0].(*ast.FuncDecl)
+			assert.Equal(t, tc.expected, classifyTestFunc(fn))
+		})
+	}
+}
+
+func TestPackageFunctionsTests(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":                  "module example.com/lib\n",
+		"lib/lib.go":              "package lib\n\nfunc Exported() {}\n",
+		"lib/lib_test.go":         "package lib\n\nimport \"testing\"\n\nfunc fixture() {}\n\nfunc TestExported(t *testing.T) {}\n",
+		"lib/example_test.go":     "package lib_test\n\nfunc ExampleExported() {}\n",
+		"lib/bench_test.go":       "package lib\n\nimport \"testing\"\n\nfunc BenchmarkExported(b *testing.B) {}\n",
+		"other/other_test.go":     "package other_test\n\nfunc ExampleOther() {}\n",
+		"other/other_lib_test.go": "package other\n\nfunc helper() {}\n",
+	})
+
+	kinds := func(opts Options) map[string]TestKind {
+		funcMap, _, err := PackageFunctions(root, "lib", opts)
+		require.NoError(t, err)
+		result := make(map[string]TestKind)
+		for _, functions := range funcMap {
+			for _, fn := range functions {
+				result[fn.Function] = fn.TestKind
+			}
+		}
+		return result
+	}
+
+	assert.Equal(t, map[string]TestKind{"Exported": ""}, kinds(Options{}))
+	assert.Equal(t, map[string]TestKind{
+		"Exported":          "",
+		"fixture":           TestKindHelper,
+		"TestExported":      TestKindTest,
+		"ExampleExported":   TestKindExample,
+		"BenchmarkExported": TestKindBenchmark,
+	}, kinds(Options{Tests: true}))
+}
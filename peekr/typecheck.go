@@ -0,0 +1,390 @@
+package peekr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// typedPackage is a package type-checked from source.
+type typedPackage struct {
+	path        string
+	dir         string
+	files       []*ast.File
+	types       *types.Package
+	info        *types.Info  // Nil for external packages
+	diagnostics []Diagnostic // Type errors
+	external    bool         // Outside the workspace, such as a stdlib package
+}
+
+// typeLoader type-checks packages from source. Packages of the workspace
+// (and its vendor directories when Options.Vendor is set) are parsed and
+// checked as they are found on disk; everything else, including the
+// standard library, is located with go/build for the same build target.
+type typeLoader struct {
+	ctx      context.Context
+	ws       *Workspace
+	opts     Options
+	fset     *token.FileSet
+	build    build.Context
+	packages map[string]*typedPackage
+	pending  map[string]*typedPackage // Parsed but not yet checked
+	loading  map[string]bool
+}
+
+// newTypeLoader returns a loader for the packages of ws. Files are selected
+// for the build target of opts, or the host platform when opts does not name
+// one.
+func newTypeLoader(ctx context.Context, fset *token.FileSet, ws *Workspace, opts Options) *typeLoader {
+	opts = opts.typeCheckTarget()
+	return &typeLoader{
+		ctx:      ctx,
+		ws:       ws,
+		opts:     opts,
+		fset:     fset,
+		build:    opts.buildContext(),
+		packages: make(map[string]*typedPackage),
+		pending:  make(map[string]*typedPackage),
+		loading:  make(map[string]bool),
+	}
+}
+
+// typeCheckTarget returns opts with a build target, defaulting to the host
+// platform. A package can only be type-checked for one target at a time.
+func (opts Options) typeCheckTarget() Options {
+	if !opts.hasBuildTarget() {
+		opts.GOOS = runtime.GOOS
+		opts.GOARCH = runtime.GOARCH
+	}
+	return opts
+}
+
+// Import implements types.Importer, resolving path from the workspace
+// directory.
+func (l *typeLoader) Import(path string) (*types.Package, error) {
+	return l.ImportFrom(path, l.ws.Dir, 0)
+}
+
+// ImportFrom implements types.ImporterFrom. srcDir is the directory of the
+// importing package, which decides how vendored packages resolve.
+func (l *typeLoader) ImportFrom(path, srcDir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	tp, err := l.load(path)
+	if err != nil {
+		return nil, err
+	}
+	if tp == nil {
+		if tp, err = l.loadExternal(path, srcDir); err != nil {
+			return nil, err
+		}
+	}
+	return tp.types, nil
+}
+
+// load returns the checked package for an import path of the workspace,
+// checking it first if needed. It returns nil for packages outside the
+// workspace.
+func (l *typeLoader) load(path string) (*typedPackage, error) {
+	if err := l.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if tp, ok := l.packages[path]; ok {
+		return tp, nil
+	}
+	if l.loading[path] {
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	if tp, ok := l.pending[path]; ok {
+		delete(l.pending, path)
+		return l.check(tp), nil
+	}
+
+	dir, ok := l.ws.resolveImportPath(path, l.opts)
+	if !ok {
+		return nil, nil
+	}
+	files, err := l.parseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return l.check(&typedPackage{path: path, dir: dir, files: files}), nil
+}
+
+// loadExternal returns the checked package for an import path outside the
+// workspace, checking it first if needed. go/build selects its files for the
+// build target of the loader, asking the go command from the root of the
+// workspace module importing it so that the module's requirements apply
+// whatever the working directory of the process. Type errors of external
+// packages are not reported.
+func (l *typeLoader) loadExternal(path, srcDir string) (*typedPackage, error) {
+	ctxt := l.build
+	ctxt.Dir = l.moduleRoot(srcDir)
+	bp, err := ctxt.Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if tp, ok := l.packages[bp.ImportPath]; ok {
+		return tp, nil
+	}
+	if l.loading[bp.ImportPath] {
+		return nil, fmt.Errorf("import cycle through %s", bp.ImportPath)
+	}
+
+	tp := &typedPackage{path: bp.ImportPath, dir: bp.Dir, external: true}
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		f, _ := parser.ParseFile(l.fset, filepath.Join(bp.Dir, name), nil, parser.SkipObjectResolution)
+		if f != nil {
+			tp.files = append(tp.files, f)
+		}
+	}
+	return l.check(tp), nil
+}
+
+// moduleRoot returns the directory of the workspace module containing dir,
+// or of the first workspace module when none does, such as for packages of
+// the module cache.
+func (l *typeLoader) moduleRoot(dir string) string {
+	root := ""
+	for _, mod := range l.ws.Modules {
+		if rel, err := filepath.Rel(mod.Dir, dir); err == nil && !strings.HasPrefix(rel, "..") && len(mod.Dir) > len(root) {
+			root = mod.Dir
+		}
+	}
+	if root == "" && len(l.ws.Modules) > 0 {
+		root = l.ws.Modules[0].Dir
+	}
+	if root == "" {
+		root = l.ws.Dir
+	}
+	return root
+}
+
+// parseDir parses the non-test files of the package in dir that are part of
+// the build. Files declaring a different package than the first one, such as
+// stray documentation files, are left out.
+func (l *typeLoader) parseDir(dir string) ([]*ast.File, error) {
+	opts := l.opts
+	opts.Tests = false
+	paths, err := goFilesIn(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if paths, err = filterBuildConstraints(paths, opts); err != nil {
+		return nil, err
+	}
+
+	var files []*ast.File
+	for _, path := range paths {
+		f, _ := parseFile(l.fset, path, nil)
+		if f == nil || f.Name == nil {
+			continue
+		}
+		if len(files) > 0 && f.Name.Name != files[0].Name.Name {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// check type-checks a package. Type errors do not stop checking; they are
+// recorded as diagnostics and the package is as complete as they allow.
+// External packages are checked without recording type information.
+func (l *typeLoader) check(tp *typedPackage) *typedPackage {
+	l.loading[tp.path] = true
+	defer delete(l.loading, tp.path)
+
+	if !tp.external {
+		tp.info = &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+	}
+	conf := types.Config{
+		Importer:    l,
+		FakeImportC: true,
+		Error: func(err error) {
+			var typeErr types.Error
+			if errors.As(err, &typeErr) && !tp.external {
+				pos := l.fset.Position(typeErr.Pos)
+				tp.diagnostics = append(tp.diagnostics, Diagnostic{File: pos.Filename, Line: pos.Line, Column: pos.Column, Message: typeErr.Msg})
+			}
+		},
+	}
+	tp.types, _ = conf.Check(tp.path, l.fset, tp.files, tp.info)
+	l.packages[tp.path] = tp
+	return tp
+}
+
+// checkParsed type-checks already parsed files, grouped into packages by
+// directory and package clause. Groups are registered before any is checked,
+// so a group importing another one, such as an external test package, sees
+// the same files rather than a second copy read from disk.
+func (l *typeLoader) checkParsed(files []*parsedFile) ([]*typedPackage, error) {
+	groups := make(map[string]*typedPackage)
+	var paths []string
+	for _, pf := range files {
+		if pf.file == nil || pf.file.Name == nil {
+			continue
+		}
+		dir := filepath.Dir(pf.path)
+		path, ok := l.ws.importPathOf(dir)
+		if !ok {
+			path = filepath.ToSlash(dir)
+		}
+		if isTestFile(pf.path) && strings.HasSuffix(pf.file.Name.Name, "_test") {
+			path += "_test"
+		}
+		tp, ok := groups[path]
+		if !ok {
+			tp = &typedPackage{path: path, dir: dir}
+			groups[path] = tp
+			paths = append(paths, path)
+		}
+		tp.files = append(tp.files, pf.file)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		l.pending[path] = groups[path]
+	}
+	var checked []*typedPackage
+	for _, path := range paths {
+		tp, err := l.load(path)
+		if err != nil {
+			return nil, err
+		}
+		checked = append(checked, tp)
+	}
+	return checked, nil
+}
+
+// attachTypes type-checks the files of pkg and records the resolved type,
+// underlying type and package-qualified name of every symbol. Type errors
+// are added to the package diagnostics.
+func attachTypes(ctx context.Context, fset *token.FileSet, pkg *Package, opts Options, files []*parsedFile) error {
+	ws, err := LoadWorkspace(pkg.Dir, opts)
+	if err != nil {
+		return err
+	}
+	checked, err := newTypeLoader(ctx, fset, ws, opts).checkParsed(files)
+	if err != nil {
+		return err
+	}
+
+	defs := make(map[string]types.Object)
+	for _, tp := range checked {
+		pkg.Diagnostics = append(pkg.Diagnostics, tp.diagnostics...)
+		for ident, obj := range tp.info.Defs {
+			if obj != nil {
+				pos := fset.Position(ident.Pos())
+				defs[positionKey(pos.Filename, pos.Line, pos.Column)] = obj
+			}
+		}
+	}
+	pkg.Diagnostics = sortDiagnostics(pkg.Diagnostics)
+
+	lookup := func(symbol Symbol) types.Object {
+		return defs[positionKey(symbol.File, symbol.Line, symbol.Column)]
+	}
+	for i := range pkg.Symbols {
+		symbol := &pkg.Symbols[i]
+		obj := lookup(*symbol)
+		if obj == nil {
+			continue
+		}
+		describeObject(symbol, obj, "")
+		for j := range symbol.Children {
+			if field := lookup(symbol.Children[j]); field != nil {
+				describeObject(&symbol.Children[j], field, symbol.QualifiedName)
+			}
+		}
+	}
+	return nil
+}
+
+// positionKey identifies a source position independently of its file set.
+func positionKey(file string, line, column int) string {
+	return fmt.Sprintf("%s:%d:%d", file, line, column)
+}
+
+// describeObject fills in the type information of symbol from its object.
+// Fields are qualified by the name of their struct, parent.
+func describeObject(symbol *Symbol, obj types.Object, parent string) {
+	switch obj := obj.(type) {
+	case *types.Func:
+		symbol.QualifiedName = obj.FullName()
+	case *types.Var:
+		if obj.IsField() && parent != "" {
+			symbol.QualifiedName = parent + "." + obj.Name()
+		}
+	default:
+		if obj.Pkg() != nil {
+			symbol.QualifiedName = obj.Pkg().Path() + "." + obj.Name()
+		}
+	}
+	typ := resolveAliases(obj.Type())
+	symbol.Type = types.TypeString(typ, nil)
+	symbol.Underlying = types.TypeString(resolveAliases(typ.Underlying()), nil)
+}
+
+// resolveAliases replaces every alias in t, including aliases nested inside
+// pointers, slices, arrays, maps, channels, struct fields and function
+// signatures, with the type it stands for.
+func resolveAliases(t types.Type) types.Type {
+	switch t := types.Unalias(t).(type) {
+	case *types.Pointer:
+		return types.NewPointer(resolveAliases(t.Elem()))
+	case *types.Slice:
+		return types.NewSlice(resolveAliases(t.Elem()))
+	case *types.Array:
+		return types.NewArray(resolveAliases(t.Elem()), t.Len())
+	case *types.Map:
+		return types.NewMap(resolveAliases(t.Key()), resolveAliases(t.Elem()))
+	case *types.Chan:
+		return types.NewChan(t.Dir(), resolveAliases(t.Elem()))
+	case *types.Struct:
+		fields := make([]*types.Var, t.NumFields())
+		tags := make([]string, t.NumFields())
+		for i := range fields {
+			f := t.Field(i)
+			fields[i] = types.NewField(f.Pos(), f.Pkg(), f.Name(), resolveAliases(f.Type()), f.Embedded())
+			tags[i] = t.Tag(i)
+		}
+		return types.NewStruct(fields, tags)
+	case *types.Signature:
+		if t.TypeParams().Len() > 0 {
+			return t
+		}
+		return types.NewSignatureType(t.Recv(), nil, nil, resolveTuple(t.Params()), resolveTuple(t.Results()), t.Variadic())
+	default:
+		return t
+	}
+}
+
+// resolveTuple applies resolveAliases to the types of a parameter list.
+func resolveTuple(tuple *types.Tuple) *types.Tuple {
+	if tuple == nil {
+		return nil
+	}
+	vars := make([]*types.Var, tuple.Len())
+	for i := range vars {
+		v := tuple.At(i)
+		vars[i] = types.NewParam(v.Pos(), v.Pkg(), v.Name(), resolveAliases(v.Type()))
+	}
+	return types.NewTuple(vars...)
+}
@@ -0,0 +1,117 @@
+package peekr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeCheck(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/tc\n",
+		"model/model.go": `package model
+
+// ID is an alias, so it must never show up in resolved types.
+type ID = int64
+
+type Item struct {
+	ID   ID
+	Tags []string
+}
+`,
+		"tc/tc.go": `package tc
+
+import (
+	"io"
+
+	. "example.com/tc/model"
+)
+
+type Reader = io.Reader
+
+type Box struct {
+	Item  Item
+	Owner *Item
+	R     Reader
+	ByID  map[ID][]*Item
+}
+
+func Open(id ID) (*Box, error) { return nil, nil }
+
+func (b *Box) Close() error { return nil }
+`,
+	})
+
+	pkg, err := Load(context.Background(), Options{Dir: root, Package: "tc", TypeCheck: true})
+	require.NoError(t, err)
+	assert.Empty(t, pkg.Diagnostics)
+
+	symbols := make(map[string]Symbol)
+	for _, symbol := range pkg.Symbols {
+		symbols[symbol.Name] = symbol
+	}
+
+	open := symbols["Open"]
+	assert.Equal(t, "example.com/tc/tc.Open", open.QualifiedName)
+	assert.Equal(t, "func(id int64) (*example.com/tc/tc.Box, error)", open.Type)
+	assert.Equal(t, "(id int64) (*example.com/tc/tc.Box, error)", open.ResolvedSignature())
+	assert.Equal(t, "(id ID) (*Box, error)", open.Signature)
+
+	assert.Equal(t, "(*example.com/tc/tc.Box).Close", symbols["Close"].QualifiedName)
+
+	box := symbols["Box"]
+	assert.Equal(t, "example.com/tc/tc.Box", box.QualifiedName)
+	assert.Equal(t, "example.com/tc/tc.Box", box.Type)
+	assert.Contains(t, box.Underlying, "struct{")
+
+	fields := make(map[string]Symbol)
+	for _, field := range box.Children {
+		fields[field.Name] = field
+	}
+	assert.Equal(t, "example.com/tc/tc.Box.Item", fields["Item"].QualifiedName)
+	assert.Equal(t, "example.com/tc/model.Item", fields["Item"].Type)
+	assert.Equal(t, "struct{ID int64; Tags []string}", fields["Item"].Underlying)
+	assert.Equal(t, "*example.com/tc/model.Item", fields["Owner"].Type)
+	assert.Equal(t, "io.Reader", fields["R"].Type)
+	assert.Equal(t, "map[int64][]*example.com/tc/model.Item", fields["ByID"].Type)
+}
+
+func TestTypeCheckErrors(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":   "module example.com/bad\n",
+		"bad/a.go": "package bad\n\nfunc A() Missing { return nil }\n",
+		"bad/b.go": "package bad\n\nfunc B() int { return 1 }\n",
+	})
+
+	pkg, err := Load(context.Background(), Options{Dir: root, Package: "bad", TypeCheck: true})
+	require.NoError(t, err)
+	require.Len(t, pkg.Diagnostics, 1)
+	assert.Contains(t, pkg.Diagnostics[0].Message, "undefined: Missing")
+	assert.Equal(t, 3, pkg.Diagnostics[0].Line)
+
+	for _, symbol := range pkg.Symbols {
+		if symbol.Name == "B" {
+			assert.Equal(t, "func() int", symbol.Type)
+		}
+	}
+}
+
+func TestTypeCheckTarget(t *testing.T) {
+	// The standard library is checked for the same target as the package,
+	// so a Windows-only declaration of syscall resolves on any host.
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":     "module example.com/win\n",
+		"win/win.go": "package win\n\nimport \"syscall\"\n\nfunc Handle() syscall.Handle { return syscall.InvalidHandle }\n",
+	})
+
+	pkg, err := Load(context.Background(), Options{Dir: root, Package: "win", GOOS: "windows", GOARCH: "amd64", TypeCheck: true})
+	require.NoError(t, err)
+	assert.Empty(t, pkg.Diagnostics)
+	require.Len(t, pkg.Symbols, 1)
+	assert.Equal(t, "func() syscall.Handle", pkg.Symbols[0].Type)
+}
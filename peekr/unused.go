@@ -0,0 +1,260 @@
+package peekr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mwiater/peekr/helpers"
+)
+
+// AllowlistFileName is the name of the file listing the intentional public
+// API of a module, which FindUnused never reports. It sits next to go.mod
+// and holds one symbol pattern per line; blank lines and lines starting with
+// "#" are ignored.
+const AllowlistFileName = ".peekrapi"
+
+// UnusedOptions controls what FindUnused considers a use.
+type UnusedOptions struct {
+	// Consumers are directories of other modules on disk whose references
+	// count as uses, such as services importing a shared library.
+	Consumers []string
+
+	// Allow lists symbols that are intentionally part of the public API, in
+	// addition to the AllowlistFileName files of the workspace modules.
+	// Patterns use path.Match syntax and are matched against both the short
+	// name ("helpers.TerminalInfo") and the full name
+	// ("github.com/mwiater/peekr/helpers.TerminalInfo") of a symbol; members
+	// of a type are named "helpers.Terminal.Width".
+	Allow []string
+}
+
+// UnusedSymbol is an exported symbol that nothing references.
+type UnusedSymbol struct {
+	Kind          string `json:"kind"`          // "func", "method", "type", "field", "const" or "var"
+	Name          string `json:"name"`          // e.g. "helpers.TerminalInfo" or "helpers.Terminal.Width"
+	QualifiedName string `json:"qualifiedName"` // Name with the package import path instead of its name
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+}
+
+// UnusedReport is the result of FindUnused.
+type UnusedReport struct {
+	Symbols     []UnusedSymbol `json:"symbols"` // Sorted by file and line
+	Allowed     int            `json:"allowed"` // Unused symbols left out because the allowlist names them
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+}
+
+// FindUnused type-checks every package of the workspace containing opts.Dir
+// and reports the exported functions, methods, types, struct fields,
+// constants and variables that no package of the workspace or of the
+// consumer modules references. References from tests only count when
+// opts.Tests is set. Main packages are skipped, and so are methods that
+// implement an interface, which may be called through it, and struct fields
+// with tags, which are usually read through reflection.
+func FindUnused(ctx context.Context, opts Options, unusedOpts UnusedOptions) (*UnusedReport, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("FindUnused(): Options.Dir is required")
+	}
+	prog, err := loadProgram(ctx, opts, unusedOpts.Consumers...)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := readAllowlists(prog.ws)
+	if err != nil {
+		return nil, err
+	}
+	allow = append(allow, unusedOpts.Allow...)
+
+	counts := prog.referenceCounts()
+	implementing := prog.interfaceMethods()
+	report := &UnusedReport{Diagnostics: prog.diagnostics}
+	check := func(kind string, obj types.Object, name string) {
+		pos := prog.fset.Position(obj.Pos())
+		if counts[positionKey(pos.Filename, pos.Line, pos.Column)] > 0 {
+			return
+		}
+		symbol := UnusedSymbol{
+			Kind:          kind,
+			Name:          obj.Pkg().Name() + "." + name,
+			QualifiedName: obj.Pkg().Path() + "." + name,
+			File:          pos.Filename,
+			Line:          pos.Line,
+		}
+		if allowed(allow, symbol) {
+			report.Allowed++
+			return
+		}
+		report.Symbols = append(report.Symbols, symbol)
+	}
+
+	for _, tp := range prog.packages {
+		if !prog.inWorkspace(tp.types) || tp.types.Name() == "main" || strings.HasSuffix(tp.path, "_test") {
+			continue
+		}
+		scope := tp.types.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if !obj.Exported() || isTestFile(prog.fset.Position(obj.Pos()).Filename) {
+				continue
+			}
+			switch obj := obj.(type) {
+			case *types.Func:
+				check("func", obj, name)
+			case *types.Const:
+				check("const", obj, name)
+			case *types.Var:
+				check("var", obj, name)
+			case *types.TypeName:
+				check("type", obj, name)
+				if obj.IsAlias() {
+					continue
+				}
+				named, ok := obj.Type().(*types.Named)
+				if !ok {
+					continue
+				}
+				for i := 0; i < named.NumMethods(); i++ {
+					method := named.Method(i)
+					if method.Exported() && !implementing[method] {
+						check("method", method, name+"."+method.Name())
+					}
+				}
+				if st, ok := named.Underlying().(*types.Struct); ok {
+					for i := 0; i < st.NumFields(); i++ {
+						field := st.Field(i)
+						if field.Exported() && !field.Embedded() && st.Tag(i) == "" {
+							check("field", field, name+"."+field.Name())
+						}
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(report.Symbols, func(i, j int) bool {
+		a, b := report.Symbols[i], report.Symbols[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return report, nil
+}
+
+// interfaceMethods returns the methods of workspace types that implement a
+// method of an interface known to the program, such as String for
+// fmt.Stringer. Such methods may only ever be called through the interface.
+func (p *program) interfaceMethods() map[*types.Func]bool {
+	ifaces := []*types.Interface{types.Universe.Lookup("error").Type().Underlying().(*types.Interface)}
+	for _, pkg := range p.allPackages() {
+		for _, obj := range namedTypes(pkg, false) {
+			if iface, ok := obj.Type().Underlying().(*types.Interface); ok && iface.NumMethods() > 0 && iface.IsMethodSet() {
+				ifaces = append(ifaces, iface)
+			}
+		}
+	}
+
+	methods := make(map[*types.Func]bool)
+	for _, tp := range p.packages {
+		if !p.inWorkspace(tp.types) {
+			continue
+		}
+		for _, obj := range namedTypes(tp.types, false) {
+			if _, ok := obj.Type().Underlying().(*types.Interface); ok {
+				continue
+			}
+			for _, iface := range ifaces {
+				if _, ok := satisfies(obj.Type(), iface); !ok {
+					continue
+				}
+				for i := 0; i < iface.NumMethods(); i++ {
+					method, _, _ := types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), iface.Method(i).Name())
+					if fn, ok := method.(*types.Func); ok {
+						methods[fn] = true
+					}
+				}
+			}
+		}
+	}
+	return methods
+}
+
+// allowed reports whether a pattern of the allowlist names symbol.
+func allowed(allow []string, symbol UnusedSymbol) bool {
+	for _, pattern := range allow {
+		if ok, _ := path.Match(pattern, symbol.Name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, symbol.QualifiedName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readAllowlists reads the AllowlistFileName file of every workspace
+// module. Missing files are skipped.
+func readAllowlists(ws *Workspace) ([]string, error) {
+	var patterns []string
+	for _, mod := range ws.Modules {
+		f, err := os.Open(filepath.Join(mod.Dir, AllowlistFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("readAllowlists(): %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				patterns = append(patterns, line)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("readAllowlists(): %w", err)
+		}
+	}
+	return patterns, nil
+}
+
+// PrintUnused prints the result of FindUnused.
+func PrintUnused(report *UnusedReport) {
+	writeUnused(os.Stdout, report)
+}
+
+// writeUnused writes the unused symbols of report to w, one per line with
+// the location of their declaration.
+func writeUnused(w io.Writer, report *UnusedReport) {
+	allowedNote := ""
+	if report.Allowed > 0 {
+		allowedNote = fmt.Sprintf(" (%d allowlisted)", report.Allowed)
+	}
+	if len(report.Symbols) == 0 {
+		helpers.FprintColor(w, "\nNo unused exported symbols"+allowedNote+".", helpers.Debug)
+		return
+	}
+	header := fmt.Sprintf("\n%d unused exported symbols%s:\n", len(report.Symbols), allowedNote)
+	helpers.FprintColor(w, header, helpers.Info)
+
+	width := 0
+	for _, symbol := range report.Symbols {
+		if n := len(symbol.Kind) + 1 + len(symbol.Name); n > width {
+			width = n
+		}
+	}
+	for _, symbol := range report.Symbols {
+		helpers.FprintColor(w, fmt.Sprintf("  %-*s  %s:%d", width, symbol.Kind+" "+symbol.Name, symbol.File, symbol.Line), helpers.Warn)
+	}
+}
@@ -0,0 +1,85 @@
+package peekr
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUnused(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"lib/go.mod": "module example.com/lib\n",
+		"lib/util/util.go": `package util
+
+import "fmt"
+
+const Version = "1"
+
+var Debug bool
+
+type Point struct {
+	X, Y int
+	Tag  string ` + "`json:\"tag\"`" + `
+}
+
+func (p Point) String() string { return fmt.Sprint(p.X) }
+
+func (p Point) Scale(n int) Point { return p }
+
+func NewPoint() Point { return Point{X: 1} }
+
+func Helper() {}
+
+func Internal() {}
+
+func unexported() {}
+`,
+		"lib/main.go": `package main
+
+import "example.com/lib/util"
+
+func Exported() {}
+
+func main() { _ = util.NewPoint() }
+`,
+		"lib/util/util_test.go": "package util\n\nimport \"testing\"\n\nfunc TestHelper(t *testing.T) { Helper() }\n",
+		"svc/go.mod":            "module example.com/svc\n",
+		"svc/svc.go":            "package svc\n\nimport \"example.com/lib/util\"\n\nvar _ = util.Version\n",
+	})
+	lib := filepath.Join(root, "lib")
+
+	names := func(report *UnusedReport) []string {
+		var names []string
+		for _, symbol := range report.Symbols {
+			names = append(names, symbol.Kind+" "+symbol.Name)
+		}
+		return names
+	}
+
+	report, err := FindUnused(context.Background(), Options{Dir: lib}, UnusedOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"const util.Version",
+		"var util.Debug",
+		"field util.Point.Y",
+		"method util.Point.Scale",
+		"func util.Helper",
+		"func util.Internal",
+	}, names(report))
+	assert.Equal(t, "example.com/lib/util.Version", report.Symbols[0].QualifiedName)
+
+	t.Run("Consumers, tests and allowlist", func(t *testing.T) {
+		writeTree(t, lib, map[string]string{AllowlistFileName: "# Public API\nutil.Point.*\n"})
+		report, err := FindUnused(context.Background(), Options{Dir: lib, Tests: true}, UnusedOptions{
+			Consumers: []string{filepath.Join(root, "svc")},
+			Allow:     []string{"example.com/lib/util.Internal"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"var util.Debug"}, names(report))
+		assert.Equal(t, 3, report.Allowed)
+	})
+}
@@ -0,0 +1,5 @@
+package peekr
+
+// Version is the peekr release. It is part of every cache key, so upgrading
+// peekr never reuses results extracted by an older version.
+const Version = "0.5.0"
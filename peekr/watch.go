@@ -0,0 +1,202 @@
+package peekr
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits after a file event for more events
+// before reloading, so that an editor saving several files triggers one redraw.
+const watchDebounce = 150 * time.Millisecond
+
+// SymbolDiff describes how the exported symbols of a package changed between
+// two loads.
+type SymbolDiff struct {
+	Changed map[string]bool // IDs of symbols that were added or whose signature changed
+	Removed []string        // IDs of symbols that no longer exist
+}
+
+// DiffPackages compares two loads of a package. A nil old package yields an
+// empty diff, so the first render in watch mode highlights nothing.
+func DiffPackages(old, new *Package) *SymbolDiff {
+	diff := &SymbolDiff{Changed: make(map[string]bool)}
+	if old == nil || new == nil {
+		return diff
+	}
+
+	before := symbolSignatures(old)
+	after := symbolSignatures(new)
+	for id, signature := range after {
+		if previous, ok := before[id]; !ok || previous != signature {
+			diff.Changed[id] = true
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// symbolSignatures maps the ID of every symbol in pkg to a string that
+// changes whenever anything printed about the symbol changes.
+func symbolSignatures(pkg *Package) map[string]string {
+	signatures := make(map[string]string)
+	for _, symbol := range pkg.Symbols {
+		signature := symbol.Signature + " " + symbol.Doc
+		for _, child := range symbol.Children {
+			signature += "; " + child.Name + " " + child.Signature
+		}
+		signatures[symbol.ID()] = signature
+	}
+	return signatures
+}
+
+// incrementalLoader keeps the parsed files of a package between loads so that
+// only files that changed are parsed again.
+type incrementalLoader struct {
+	dir     string
+	pkgName string
+	opts    Options
+	fset    *token.FileSet
+	parsed  map[string]*parsedFile
+}
+
+// load rediscovers the package's files, parses the ones that are new or
+// listed in changed, drops the ones that disappeared, and rebuilds the
+// package. It also returns the directories that should be watched.
+func (l *incrementalLoader) load(ctx context.Context, changed map[string]bool) (*Package, []string, error) {
+	target, err := resolveTarget(ctx, l.dir, l.pkgName, l.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stale []string
+	for _, path := range target.files {
+		if _, ok := l.parsed[path]; !ok || changed[path] {
+			stale = append(stale, path)
+		}
+	}
+	reparsed, err := parseFiles(ctx, l.fset, stale, l.opts, target.clauseFilter())
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, pf := range reparsed {
+		l.parsed[stale[i]] = pf
+	}
+
+	current := make(map[string]bool, len(target.files))
+	parsed := make([]*parsedFile, 0, len(target.files))
+	dirs := make(map[string]bool)
+	for _, path := range target.files {
+		current[path] = true
+		parsed = append(parsed, l.parsed[path])
+		dirs[filepath.Dir(path)] = true
+	}
+	for path := range l.parsed {
+		if !current[path] {
+			delete(l.parsed, path)
+		}
+	}
+
+	ws, err := LoadWorkspace(l.dir, l.opts)
+	if err == nil {
+		for _, root := range ws.Roots() {
+			dirs[root] = true
+		}
+	}
+	watchDirs := make([]string, 0, len(dirs))
+	for d := range dirs {
+		watchDirs = append(watchDirs, d)
+	}
+	sort.Strings(watchDirs)
+
+	pkg, err := buildPackage(l.fset, l.dir, l.pkgName, l.opts, target, parsed)
+	if err == nil && l.opts.TypeCheck {
+		err = attachTypes(ctx, l.fset, pkg, l.opts, target.packageFiles(parsed))
+	}
+	return pkg, watchDirs, err
+}
+
+// Watch loads the package and calls onChange with the result, then watches
+// the package's directories and calls onChange again after every batch of
+// file changes. Only the files that changed are parsed again. The diff passed
+// to onChange compares against the previous successful load. Load errors,
+// such as the package not existing yet, are passed to onChange as well and do
+// not stop watching. With Options.TypeCheck, the package is type-checked
+// again on every reload. Watch returns when ctx is cancelled.
+func Watch(ctx context.Context, dir, pkgName string, opts Options, onChange func(pkg *Package, diff *SymbolDiff, err error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Watch(): %w", err)
+	}
+	defer watcher.Close()
+
+	if opts.TypeCheck {
+		opts = opts.typeCheckTarget()
+	}
+	loader := &incrementalLoader{
+		dir:     dir,
+		pkgName: pkgName,
+		opts:    opts,
+		fset:    token.NewFileSet(),
+		parsed:  make(map[string]*parsedFile),
+	}
+	watched := make(map[string]bool)
+	var previous *Package
+
+	reload := func(changed map[string]bool) {
+		pkg, dirs, err := loader.load(ctx, changed)
+		if ctx.Err() != nil {
+			return
+		}
+		for _, d := range dirs {
+			if !watched[d] && watcher.Add(d) == nil {
+				watched[d] = true
+			}
+		}
+		if err != nil {
+			onChange(nil, nil, err)
+			return
+		}
+		onChange(pkg, DiffPackages(previous, pkg), nil)
+		previous = pkg
+	}
+	reload(nil)
+
+	changed := make(map[string]bool)
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !strings.HasSuffix(event.Name, ".go") && !event.Has(fsnotify.Create) {
+				continue
+			}
+			changed[event.Name] = true
+			timer.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			onChange(nil, nil, fmt.Errorf("Watch(): %w", err))
+		case <-timer.C:
+			reload(changed)
+			changed = make(map[string]bool)
+		}
+	}
+}
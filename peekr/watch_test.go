@@ -0,0 +1,135 @@
+package peekr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffPackages(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":     "module example.com/w\n",
+		"w/a.go":     "package w\n\nfunc Same() {}\n\nfunc Changed(a int) {}\n\nfunc Gone() {}\n\ntype Kept struct{ A int }\n",
+		"w/other.go": "package w\n\ntype Grown struct{ A int }\n",
+	})
+	old, err := LoadPackage(root, "w", Options{})
+	require.NoError(t, err)
+
+	writeTree(t, root, map[string]string{
+		"w/a.go":     "package w\n\nfunc Same() {}\n\nfunc Changed(a, b int) {}\n\nfunc Added() {}\n\ntype Kept struct{ A int }\n",
+		"w/other.go": "package w\n\ntype Grown struct {\n\tA int\n\tB string\n}\n",
+	})
+	new, err := LoadPackage(root, "w", Options{})
+	require.NoError(t, err)
+
+	diff := DiffPackages(old, new)
+	assert.Equal(t, map[string]bool{
+		"func w.Changed": true,
+		"func w.Added":   true,
+		"struct w.Grown": true,
+	}, diff.Changed)
+	assert.Equal(t, []string{"func w.Gone"}, diff.Removed)
+
+	assert.Empty(t, DiffPackages(nil, new).Changed)
+}
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/w\n",
+		"w/a.go": "package w\n\nfunc First() {}\n",
+	})
+
+	type update struct {
+		pkg  *Package
+		diff *SymbolDiff
+		err  error
+	}
+	updates := make(chan update, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, root, "w", Options{}, func(pkg *Package, diff *SymbolDiff, err error) {
+			updates <- update{pkg, diff, err}
+		})
+	}()
+
+	next := func() update {
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for watch update")
+			return update{}
+		}
+	}
+
+	initial := next()
+	require.NoError(t, initial.err)
+	assert.Len(t, initial.pkg.Symbols, 1)
+
+	path := filepath.Join(root, "w", "b.go")
+	require.NoError(t, os.WriteFile(path, []byte("package w\n\nfunc Second() {}\n"), 0o644))
+	changed := next()
+	require.NoError(t, changed.err)
+	assert.Len(t, changed.pkg.Symbols, 2)
+	assert.Equal(t, map[string]bool{"func w.Second": true}, changed.diff.Changed)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchTypeCheck(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/w\n",
+		"w/a.go": "package w\n\ntype ID int\n\ntype Box struct{ N ID }\n",
+	})
+
+	updates := make(chan *Package, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, root, "w", Options{TypeCheck: true}, func(pkg *Package, _ *SymbolDiff, err error) {
+			assert.NoError(t, err)
+			updates <- pkg
+		})
+	}()
+
+	next := func() *Package {
+		select {
+		case pkg := <-updates:
+			return pkg
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for watch update")
+			return nil
+		}
+	}
+	// The underlying type of Box.N follows the declaration of ID.
+	underlying := func(pkg *Package) string {
+		for _, symbol := range pkg.Symbols {
+			if symbol.Name == "Box" && len(symbol.Children) == 1 {
+				return symbol.Children[0].Underlying
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, "int", underlying(next()))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "w", "a.go"), []byte("package w\n\ntype ID string\n\ntype Box struct{ N ID }\n"), 0o644))
+	// The write may be seen half done first; wait for the full file.
+	pkg := next()
+	for len(pkg.Symbols) == 0 {
+		pkg = next()
+	}
+	assert.Equal(t, "string", underlying(pkg))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
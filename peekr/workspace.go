@@ -0,0 +1,269 @@
+package peekr
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Module describes a Go module that takes part in a scan.
+type Module struct {
+	Path string // Module path declared in go.mod
+	Dir  string // Absolute directory containing go.mod
+}
+
+// Workspace describes the modules reachable from a scan directory.
+// When a go.work file is in use, every module it lists is part of the
+// workspace; otherwise the workspace is the module enclosing the directory.
+type Workspace struct {
+	Dir     string   // Absolute directory that was requested
+	GoWork  string   // Path of the go.work file in use, empty if none
+	Modules []Module // Modules that make up the workspace
+}
+
+// LoadWorkspace looks for a go.work file in dir or its parents (or the file
+// named by the GOWORK environment variable) and the go.mod files of every module it uses.
+// Setting GOWORK=off or Options.NoWorkspace disables workspace mode.
+func LoadWorkspace(dir string, opts Options) (*Workspace, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("LoadWorkspace(): resolving %q: %w", dir, err)
+	}
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("LoadWorkspace(): %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("LoadWorkspace(): %s is not a directory", absDir)
+	}
+
+	ws := &Workspace{Dir: absDir}
+	if goWork := findGoWork(absDir, opts); goWork != "" {
+		dirs, err := parseGoWork(goWork)
+		if err != nil {
+			return nil, err
+		}
+		ws.GoWork = goWork
+		for _, modDir := range dirs {
+			modPath, err := parseModulePath(filepath.Join(modDir, "go.mod"))
+			if err != nil {
+				return nil, err
+			}
+			ws.Modules = append(ws.Modules, Module{Path: modPath, Dir: modDir})
+		}
+		return ws, nil
+	}
+
+	// Outside of workspace mode, the enclosing module (if any) is used to
+	// resolve import paths.
+	if modDir, ok := findEnclosingModule(absDir); ok {
+		modPath, err := parseModulePath(filepath.Join(modDir, "go.mod"))
+		if err != nil {
+			return nil, err
+		}
+		ws.Modules = append(ws.Modules, Module{Path: modPath, Dir: modDir})
+	}
+	return ws, nil
+}
+
+// Roots returns the directories that should be walked to discover source files.
+// In workspace mode this is every module directory; otherwise it is Dir alone.
+func (ws *Workspace) Roots() []string {
+	if ws.GoWork == "" {
+		return []string{ws.Dir}
+	}
+	roots := make([]string, 0, len(ws.Modules))
+	for _, mod := range ws.Modules {
+		roots = append(roots, mod.Dir)
+	}
+	return roots
+}
+
+// ModuleFor returns the workspace module that owns the given import path,
+// preferring the longest matching module path.
+func (ws *Workspace) ModuleFor(importPath string) (Module, bool) {
+	var best Module
+	found := false
+	for _, mod := range ws.Modules {
+		if importPath != mod.Path && !strings.HasPrefix(importPath, mod.Path+"/") {
+			continue
+		}
+		if !found || len(mod.Path) > len(best.Path) {
+			best = mod
+			found = true
+		}
+	}
+	return best, found
+}
+
+// isModuleRoot reports whether dir is the root of one of the workspace modules.
+func (ws *Workspace) isModuleRoot(dir string) bool {
+	for _, mod := range ws.Modules {
+		if mod.Dir == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// findGoWork returns the go.work file that applies to dir, or an empty
+// string. Like the go command, it looks in dir and then in its parents.
+func findGoWork(dir string, opts Options) string {
+	if opts.NoWorkspace {
+		return ""
+	}
+	switch env := os.Getenv("GOWORK"); env {
+	case "off":
+		return ""
+	case "":
+	default:
+		return env
+	}
+	for {
+		candidate := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findEnclosingModule walks up from dir until it finds a directory containing go.mod.
+func findEnclosingModule(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// parseGoWork returns the absolute module directories named by the use
+// directives of a go.work file. Both the single-line and block forms are supported.
+func parseGoWork(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("parseGoWork(): %w", err)
+	}
+	defer f.Close()
+
+	base := filepath.Dir(path)
+	var dirs []string
+	inBlock := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := stripModComment(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var arg string
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+			arg = line
+		case line == "use (" || line == "use(":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "use "):
+			arg = strings.TrimSpace(strings.TrimPrefix(line, "use "))
+		default:
+			continue
+		}
+
+		modDir := unquoteModArg(arg)
+		if !filepath.IsAbs(modDir) {
+			modDir = filepath.Join(base, modDir)
+		}
+		dirs = append(dirs, filepath.Clean(modDir))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parseGoWork(): %w", err)
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// parseModulePath returns the module path declared by a go.mod file.
+func parseModulePath(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("parseModulePath(): %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = stripModComment(line)
+		if strings.HasPrefix(line, "module ") {
+			return unquoteModArg(strings.TrimSpace(strings.TrimPrefix(line, "module "))), nil
+		}
+	}
+	return "", fmt.Errorf("parseModulePath(): no module directive in %s", path)
+}
+
+// parseModuleRequires returns the module paths named by the require
+// directives of a go.mod file. Both the single-line and block forms are
+// supported.
+func parseModuleRequires(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("parseModuleRequires(): %w", err)
+	}
+
+	var requires []string
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = stripModComment(line)
+		var arg string
+		switch {
+		case line == "":
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+			arg = line
+		case line == "require (" || line == "require(":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "require "):
+			arg = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		default:
+			continue
+		}
+		if fields := strings.Fields(arg); len(fields) > 0 {
+			requires = append(requires, unquoteModArg(fields[0]))
+		}
+	}
+	return requires, nil
+}
+
+// stripModComment removes a trailing // comment and surrounding whitespace
+// from a go.mod or go.work line.
+func stripModComment(line string) string {
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// unquoteModArg removes the optional quotes around a go.mod or go.work argument.
+func unquoteModArg(arg string) string {
+	if unquoted, err := strconv.Unquote(arg); err == nil {
+		return unquoted
+	}
+	return arg
+}
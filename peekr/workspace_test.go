@@ -0,0 +1,114 @@
+package peekr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree creates the given files (relative path to contents) under root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+}
+
+func TestParseGoWork(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.work": "go 1.21\n\nuse ./single // trailing comment\n\nuse (\n\t./a\n\t\"./b\"\n)\n",
+	})
+
+	dirs, err := parseGoWork(filepath.Join(root, "go.work"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "b"),
+		filepath.Join(root, "single"),
+	}, dirs)
+}
+
+func TestParseModulePath(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "// leading comment\nmodule \"example.com/quoted\"\n\ngo 1.21\n",
+	})
+
+	path, err := parseModulePath(filepath.Join(root, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/quoted", path)
+}
+
+func TestPackageFunctionsWorkspace(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("GOWORK", "")
+	writeTree(t, root, map[string]string{
+		"go.work":                                "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod":                               "module example.com/a\n",
+		"a/util/util.go":                         "package util\n\nfunc FromA() {}\n",
+		"b/go.mod":                               "module example.com/b\n",
+		"b/util/util.go":                         "package util\n\nfunc FromB() {}\n",
+		"b/vendor/modules.txt":                   "# example.com/dep v1.0.0\nexample.com/dep/util\n",
+		"b/vendor/example.com/dep/util/util.go":  "package util\n\nfunc FromVendor() {}\n",
+		"b/vendor/example.com/dep/extra/util.go": "package util\n\nfunc Unlisted() {}\n",
+		"c/go.mod":                               "module example.com/c\n",
+		"c/util/util.go":                         "package util\n\nfunc FromC() {}\n",
+	})
+
+	names := func(funcMap map[string][]FunctionInfo) []string {
+		var result []string
+		for _, functions := range funcMap {
+			for _, fn := range functions {
+				result = append(result, fn.Function)
+			}
+		}
+		return result
+	}
+
+	t.Run("Workspace modules only", func(t *testing.T) {
+		funcMap, _, err := PackageFunctions(root, "util", Options{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"FromA", "FromB"}, names(funcMap))
+	})
+
+	t.Run("Vendor honors modules.txt", func(t *testing.T) {
+		funcMap, _, err := PackageFunctions(root, "util", Options{Vendor: true})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"FromA", "FromB", "FromVendor"}, names(funcMap))
+	})
+
+	t.Run("Import path across modules", func(t *testing.T) {
+		funcMap, _, err := PackageFunctions(root, "example.com/b/util", Options{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"FromB"}, names(funcMap))
+	})
+
+	t.Run("Vendored import path", func(t *testing.T) {
+		funcMap, _, err := PackageFunctions(root, "example.com/dep/util", Options{Vendor: true})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"FromVendor"}, names(funcMap))
+	})
+
+	t.Run("Module subdirectory", func(t *testing.T) {
+		ws, err := LoadWorkspace(filepath.Join(root, "a", "util"), Options{})
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "go.work"), ws.GoWork)
+		assert.Len(t, ws.Modules, 2)
+
+		funcMap, _, err := PackageFunctions(filepath.Join(root, "a"), "example.com/b/util", Options{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"FromB"}, names(funcMap))
+	})
+
+	t.Run("No workspace", func(t *testing.T) {
+		funcMap, _, err := PackageFunctions(root, "util", Options{NoWorkspace: true})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"FromA", "FromB", "FromC"}, names(funcMap))
+	})
+}